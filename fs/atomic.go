@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to name like [os.WriteFile] but readers never
+// see a partially written file: the data is written to a temporary file in the
+// same directory, flushed to disk and renamed over name. If anything fails, name
+// is left untouched and the temporary file is removed.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	err := writeAtomic(name, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	return wrapErr("write", "", name, err)
+}
+
+// CopyReaderAtomic does the same as [WriteFileAtomic] but the content is read
+// from r, so it works with contents of any size. It returns the number of bytes
+// written.
+func CopyReaderAtomic(name string, r io.Reader, perm os.FileMode) (int64, error) {
+	var n int64
+	err := writeAtomic(name, perm, func(w io.Writer) error {
+		var err error
+		n, err = io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return 0, wrapErr("write", "", name, err)
+	}
+	return n, nil
+}
+
+func writeAtomic(name string, perm os.FileMode, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Once renamed, removing the temporary path is a harmless no-op.
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,183 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// AuditRule is a rule checked by [Audit].
+type AuditRule string
+
+const (
+	// RuleWorldWritable is broken by entries anyone can write to.
+	// Directories with the sticky bit (like /tmp) are allowed.
+	RuleWorldWritable AuditRule = "world-writable"
+	// RuleSetuid is broken by entries with the setuid bit and files with the
+	// setgid bit. Directories with the setgid bit (the usual way to share
+	// them with a group) are allowed.
+	RuleSetuid AuditRule = "setuid"
+	// RuleOwner is broken by entries not owned by any of
+	// [AuditRules.Owners].
+	RuleOwner AuditRule = "owner"
+	// RuleDirMode is broken by directories with permissions beyond
+	// [AuditRules.MaxDirMode].
+	RuleDirMode AuditRule = "dir-mode"
+)
+
+// AuditRules sets which rules [Audit] checks. The zero value checks nothing.
+type AuditRules struct {
+	WorldWritable bool
+	Setuid        bool
+	// Owners are the user ids allowed to own entries. If it's empty,
+	// ownership isn't checked. With Fix, entries owned by anyone else are
+	// given to the first one. It's only supported on unix systems.
+	Owners []int
+	// MaxDirMode is the widest permissions allowed for directories (eg:
+	// 0755). If it's 0, directory permissions aren't checked.
+	MaxDirMode os.FileMode
+	// Ignore has patterns of entries left out of the audit, with the syntax
+	// of [TreeOptions.Ignore].
+	Ignore []string
+	// Fix corrects the violations: write permission for others, the setuid
+	// and setgid bits that break RuleSetuid and permissions beyond MaxDirMode
+	// are removed and entries are given to the first of Owners (which usually
+	// requires root). Directories are fixed once their contents have been
+	// audited, so removing permissions doesn't stop the walk.
+	Fix bool
+}
+
+// AuditViolation is a rule broken by an entry.
+type AuditViolation struct {
+	Path string
+	Rule AuditRule
+	Mode os.FileMode // Mode of the entry when it was audited
+	UID  int
+	// Fixed is true if the violation was corrected, if correcting it failed,
+	// FixErr has the reason.
+	Fixed  bool
+	FixErr error
+}
+
+// Audit walks the tree at root and returns the entries that break any of the
+// rules, like a security scanner would (eg: to check a deployment before
+// serving it). Symlinks aren't audited since their permissions aren't used.
+// Entries that can't be read are skipped and reported in the returned error,
+// after auditing the rest of the tree.
+func Audit(root string, rules AuditRules) ([]AuditViolation, error) {
+	var violations []AuditViolation
+	var dirFixes []auditFix
+	var errs []error
+	// Errors are collected in errs so the walk goes on, it never fails.
+	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, wrapErr("audit", path, "", err))
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			errs = append(errs, wrapErr("audit", path, "", err))
+			return nil
+		}
+		if path != root && ignored(rules.Ignore, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			errs = append(errs, &Error{Op: "lstat", Source: path, Err: err})
+			return nil
+		}
+
+		found, fixed := auditEntry(path, info, rules)
+		if len(found) == 0 {
+			return nil
+		}
+		fix := auditFix{path, fixed, len(violations), len(violations) + len(found)}
+		violations = append(violations, found...)
+		if rules.Fix {
+			if info.IsDir() {
+				dirFixes = append(dirFixes, fix)
+			} else {
+				fix.apply(violations, rules)
+			}
+		}
+		return nil
+	})
+	// Directories go after their contents (the walk visits them before), so
+	// they're still readable while their contents are fixed.
+	for i := len(dirFixes) - 1; i >= 0; i-- {
+		dirFixes[i].apply(violations, rules)
+	}
+	return violations, errors.Join(errs...)
+}
+
+// auditEntry returns the violations of a single entry and the mode it would
+// have once they're fixed.
+func auditEntry(path string, info os.FileInfo, rules AuditRules) ([]AuditViolation, os.FileMode) {
+	const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	mode := info.Mode() & modeBits
+	uid, _, hasOwner := ownerOf(info)
+
+	var violations []AuditViolation
+	fixed := mode
+	if rules.WorldWritable && mode&0002 != 0 && !(info.IsDir() && mode&os.ModeSticky != 0) {
+		violations = append(violations, AuditViolation{Path: path, Rule: RuleWorldWritable})
+		fixed &^= 0002
+	}
+	if rules.Setuid {
+		special := mode & os.ModeSetuid
+		if !info.IsDir() {
+			special |= mode & os.ModeSetgid
+		}
+		if special != 0 {
+			violations = append(violations, AuditViolation{Path: path, Rule: RuleSetuid})
+			fixed &^= special
+		}
+	}
+	if rules.MaxDirMode != 0 && info.IsDir() && mode.Perm()&^rules.MaxDirMode.Perm() != 0 {
+		violations = append(violations, AuditViolation{Path: path, Rule: RuleDirMode})
+		fixed &^= mode.Perm() &^ rules.MaxDirMode.Perm()
+	}
+	if len(rules.Owners) > 0 && hasOwner && !slices.Contains(rules.Owners, uid) {
+		violations = append(violations, AuditViolation{Path: path, Rule: RuleOwner})
+	}
+
+	for i := range violations {
+		violations[i].Mode, violations[i].UID = mode, uid
+	}
+	return violations, fixed
+}
+
+// auditFix is the fix of the violations[first:last] of an entry.
+type auditFix struct {
+	path        string
+	mode        os.FileMode // Mode once fixed
+	first, last int
+}
+
+func (f auditFix) apply(violations []AuditViolation, rules AuditRules) {
+	found := violations[f.first:f.last]
+	var chmodErr error
+	if f.mode != found[0].Mode {
+		if err := os.Chmod(f.path, f.mode); err != nil {
+			chmodErr = &Error{Op: "chmod", Source: f.path, Err: err}
+		}
+	}
+	for i := range found {
+		if found[i].Rule == RuleOwner {
+			if err := os.Lchown(f.path, rules.Owners[0], -1); err != nil {
+				found[i].FixErr = &Error{Op: "chown", Source: f.path, Err: err}
+			}
+		} else {
+			found[i].FixErr = chmodErr
+		}
+		found[i].Fixed = found[i].FixErr == nil
+	}
+}
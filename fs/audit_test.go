@@ -0,0 +1,179 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestAudit(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("unix permissions are needed")
+	}
+	root := t.TempDir()
+	err := fs.Fixture{
+		"bin/tool":       fs.FileEntry("").WithMode(0755),
+		"bin/suid":       fs.FileEntry("").WithMode(0755),
+		"public/":        fs.DirEntry().WithMode(0777),
+		"public/shared":  fs.FileEntry("").WithMode(0644),
+		"tmp/":           fs.DirEntry().WithMode(0777),
+		"ignored/":       fs.DirEntry().WithMode(0777),
+		"link-to-public": fs.SymlinkEntry("public"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Special bits and world-writable files can't be created through the
+	// umask.
+	for path, mode := range map[string]os.FileMode{
+		"bin/suid":      0755 | os.ModeSetuid,
+		"public/shared": 0666,
+		"tmp":           0777 | os.ModeSticky,
+	} {
+		if err := os.Chmod(filepath.Join(root, path), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := fs.AuditRules{
+		WorldWritable: true,
+		Setuid:        true,
+		MaxDirMode:    0755,
+		Owners:        []int{os.Getuid() + 12345},
+		Ignore:        []string{"ignored"},
+	}
+	violations, err := fs.Audit(root, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, v := range violations {
+		rel, _ := filepath.Rel(root, v.Path)
+		if v.Rule == fs.RuleOwner {
+			continue
+		}
+		got[filepath.ToSlash(rel)+" "+string(v.Rule)] = true
+		if v.Fixed {
+			t.Errorf("%s was fixed without Fix", rel)
+		}
+	}
+	want := []string{
+		"bin/suid setuid",
+		"public world-writable",
+		"public dir-mode",
+		"public/shared world-writable",
+		"tmp dir-mode",
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing violation %s", w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if len(violations) == len(got) {
+		t.Error("expected owner violations")
+	}
+
+	rules.Owners = nil
+	rules.Fix = true
+	if _, err := fs.Audit(root, rules); err != nil {
+		t.Fatal(err)
+	}
+	violations, err = fs.Audit(root, fs.AuditRules{WorldWritable: true, Setuid: true, MaxDirMode: 0755, Ignore: rules.Ignore})
+	if err != nil || len(violations) != 0 {
+		t.Errorf("expected every violation to be fixed, got %+v (%v)", violations, err)
+	}
+	info, err := os.Stat(filepath.Join(root, "tmp"))
+	if err != nil || info.Mode()&os.ModeSticky == 0 || info.Mode().Perm() != 0755 {
+		t.Errorf("expected tmp to keep the sticky bit with 0755, got %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestAuditSetgidDir(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("unix permissions are needed")
+	}
+	root := t.TempDir()
+	err := fs.Fixture{
+		"team/":     fs.DirEntry(),
+		"team/tool": fs.FileEntry(""),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, mode := range map[string]os.FileMode{
+		"team":      0775 | os.ModeSetgid,
+		"team/tool": 0755 | os.ModeSetgid,
+	} {
+		if err := os.Chmod(filepath.Join(root, path), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	violations, err := fs.Audit(root, fs.AuditRules{Setuid: true, Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Path != filepath.Join(root, "team/tool") || !violations[0].Fixed {
+		t.Errorf("expected only team/tool to be reported and fixed, got %+v", violations)
+	}
+	info, err := os.Stat(filepath.Join(root, "team"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSetgid == 0 {
+		t.Errorf("expected team to keep the setgid bit, got %v", info.Mode())
+	}
+}
+
+func TestAuditFixDirsLast(t *testing.T) {
+	if os.PathSeparator != '/' {
+		t.Skip("unix permissions are needed")
+	}
+	root := t.TempDir()
+	err := fs.Fixture{
+		"a/b/c.txt": fs.FileEntry(""),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "a/b/c.txt"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.RemoveAll(root) })
+
+	// Fixing a and b before auditing their contents would make them
+	// unreadable.
+	violations, err := fs.Audit(filepath.Join(root, "a"), fs.AuditRules{WorldWritable: true, MaxDirMode: 0600, Fix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := map[string]bool{}
+	for _, v := range violations {
+		rel, _ := filepath.Rel(root, v.Path)
+		fixed[filepath.ToSlash(rel)+" "+string(v.Rule)] = v.Fixed
+	}
+	for _, w := range []string{"a dir-mode", "a/b dir-mode", "a/b/c.txt world-writable"} {
+		if !fixed[w] {
+			t.Errorf("expected %s to be fixed, got %v", w, fixed)
+		}
+	}
+	for _, dir := range []string{"a", "a/b"} {
+		path := filepath.Join(root, dir)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("expected %s to have mode 0600, got %v", dir, info.Mode())
+		}
+		// So the next one can be checked.
+		if err := os.Chmod(path, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
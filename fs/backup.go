@@ -0,0 +1,166 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BackupMode is how [BackupFile] names backups, it mirrors the values of the
+// --backup flag of GNU cp.
+type BackupMode int
+
+const (
+	// NoBackup replaces entries without keeping a backup.
+	NoBackup BackupMode = iota
+	// SimpleBackup appends the suffix to the name (eg: name~), replacing the
+	// previous backup.
+	SimpleBackup
+	// NumberedBackup appends an increasing number to the name (eg: name.~3~).
+	NumberedBackup
+	// ExistingBackup makes numbered backups of the entries that already have
+	// numbered backups and simple ones of the rest.
+	ExistingBackup
+)
+
+// BackupOptions tunes [BackupFile].
+type BackupOptions struct {
+	Mode BackupMode
+	// Suffix is appended to simple backups, "~" by default.
+	Suffix string
+	// MaxBackups is the number of numbered backups kept per entry, the oldest
+	// ones are removed. If it's 0, every backup is kept.
+	MaxBackups int
+}
+
+// BackupFile renames the entry at path (a file, directory or symlink) to a
+// backup name according to opts and returns that name. It returns an empty
+// string if path doesn't exist or opts.Mode is [NoBackup]. Since backups are
+// renamed instead of copied, they keep the inode, permissions and times of the
+// original entry.
+func BackupFile(path string, opts BackupOptions) (string, error) {
+	backup, err := backupFile(path, opts)
+	return backup, wrapErr("backup", path, backup, err)
+}
+
+func backupFile(path string, opts BackupOptions) (string, error) {
+	if opts.Mode == NoBackup {
+		return "", nil
+	}
+	exists, err := Lexists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	numbers, err := numberedBackups(path)
+	if err != nil {
+		return "", err
+	}
+	mode := opts.Mode
+	if mode == ExistingBackup {
+		mode = SimpleBackup
+		if len(numbers) > 0 {
+			mode = NumberedBackup
+		}
+	}
+
+	var backup string
+	if mode == SimpleBackup {
+		suffix := opts.Suffix
+		if suffix == "" {
+			suffix = "~"
+		}
+		backup = path + suffix
+		// Simple backups replace the previous one.
+		if err := os.RemoveAll(backup); err != nil {
+			return "", err
+		}
+	} else {
+		n := 1
+		if len(numbers) > 0 {
+			n = numbers[len(numbers)-1] + 1
+		}
+		backup = numberedBackupName(path, n)
+		numbers = append(numbers, n)
+	}
+
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+
+	if mode == NumberedBackup && opts.MaxBackups > 0 {
+		for _, n := range numbers[:max(len(numbers)-opts.MaxBackups, 0)] {
+			if err := os.RemoveAll(numberedBackupName(path, n)); err != nil {
+				return backup, err
+			}
+		}
+	}
+	return backup, nil
+}
+
+func numberedBackupName(path string, n int) string {
+	return fmt.Sprintf("%s.~%d~", path, n)
+}
+
+// numberedBackups returns the numbers of the existing numbered backups of path
+// in ascending order.
+func numberedBackups(path string) ([]int, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(path) + ".~"
+	var numbers []int
+	for _, e := range entries {
+		rest, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		rest, ok = strings.CutSuffix(rest, "~")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(rest); err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// MoveWithOptions does the same as [Move] but, if opts allow it, it replaces
+// dest (backing it up first if [CopyOptions.Backup] is set). Without backups, a
+// dest directory is only replaced if it's empty, like the mv command does.
+func MoveWithOptions(source, dest string, opts CopyOptions) error {
+	srcInfo, err := os.Lstat(source)
+	if err != nil {
+		return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+	}
+	dstInfo, err := os.Lstat(dest)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+		}
+		return move(source, dest)
+	}
+
+	if !opts.Overwrite {
+		return &Error{Op: "move", Source: source, Dest: dest, Err: errDestExists}
+	}
+	if os.SameFile(srcInfo, dstInfo) {
+		return &Error{Op: "move", Source: source, Dest: dest, Err: errSameFile}
+	}
+	if opts.Backup.Mode != NoBackup {
+		_, err = BackupFile(dest, opts.Backup)
+		err = wrapErr("backup", source, dest, err)
+	} else {
+		err = wrapErr("remove", source, dest, os.Remove(dest))
+	}
+	if err != nil {
+		return err
+	}
+	return move(source, dest)
+}
@@ -0,0 +1,97 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestCopyWithBackups(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/a.txt":     fs.FileEntry("new a"),
+		"src/sub/b.txt": fs.FileEntry("new b"),
+		"dst/a.txt":     fs.FileEntry("old a"),
+		"dst/sub/b.txt": fs.FileEntry("old b"),
+		"dst/keep.txt":  fs.FileEntry("keep"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+
+	if err := fs.CopyDir(src, dst); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected os.ErrExist without Overwrite, got %v", err)
+	}
+
+	opts := fs.CopyOptions{Overwrite: true, Backup: fs.BackupOptions{Mode: fs.SimpleBackup}}
+	if err := fs.CopyDirWithOptions(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, dst, fs.Fixture{
+		"a.txt":      fs.FileEntry("new a"),
+		"a.txt~":     fs.FileEntry("old a"),
+		"sub/b.txt":  fs.FileEntry("new b"),
+		"sub/b.txt~": fs.FileEntry("old b"),
+		"keep.txt":   fs.FileEntry("keep"),
+	})
+
+	// Numbered backups keep the last 2 versions.
+	a := filepath.Join(dst, "a.txt")
+	opts.Backup = fs.BackupOptions{Mode: fs.NumberedBackup, MaxBackups: 2}
+	for _, content := range []string{"v2", "v3", "v4"} {
+		if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.CopyFileWithOptions(filepath.Join(src, "a.txt"), a, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{"a.txt": "v4", "a.txt.~2~": "v2", "a.txt.~3~": "v3"} {
+		if got, err := os.ReadFile(filepath.Join(dst, name)); err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if exists, _ := fs.Exists(filepath.Join(dst, "a.txt.~1~")); exists {
+		t.Error("the oldest numbered backup was not removed")
+	}
+
+	// Existing mode keeps numbering files with numbered backups and makes
+	// simple backups of the rest.
+	opts.Backup = fs.BackupOptions{Mode: fs.ExistingBackup, Suffix: ".bak"}
+	if _, err := fs.CopyFileWithOptions(a, filepath.Join(dst, "keep.txt"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if backup, err := fs.BackupFile(a, opts.Backup); err != nil || backup != a+".~4~" {
+		t.Errorf("expected a numbered backup, got %q (%v)", backup, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, "keep.txt.bak")); string(got) != "keep" {
+		t.Errorf("expected a simple backup of keep.txt, got %q", got)
+	}
+}
+
+func TestMoveWithBackup(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"new": fs.FileEntry("new"),
+		"old": fs.FileEntry("old"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := fs.CopyOptions{Overwrite: true, Backup: fs.BackupOptions{Mode: fs.NumberedBackup}}
+	if err := fs.MoveWithOptions(filepath.Join(root, "new"), filepath.Join(root, "old"), opts); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"old":     fs.FileEntry("new"),
+		"old.~1~": fs.FileEntry("old"),
+	})
+
+	if _, err := fs.CopyFileWithOptions(filepath.Join(root, "old"), filepath.Join(root, "old"), opts); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("expected copying a file over itself to fail, got %v", err)
+	}
+}
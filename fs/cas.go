@@ -0,0 +1,407 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CAS is a content-addressed blob store on disk. Each blob is stored once
+// under the SHA-256 of it's content, sharded in 2 levels of directories
+// (objects/ab/cd/abcd...) so no directory grows too big, which makes it a
+// good fit for build caches and other deduplicated storage. Blobs are written
+// atomically and made read-only, so a CAS is safe for concurrent use by
+// several goroutines and processes.
+type CAS struct {
+	root string
+}
+
+// CASEntry is an entry of a tree stored with [CAS.PutTree]. Path uses forward
+// slashes and is relative to the root of the tree. Directories and symlinks
+// don't have a digest.
+type CASEntry struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Digest string      `json:"digest,omitempty"`
+	Target string      `json:"target,omitempty"`
+}
+
+// casTree is the blob that describes a tree, the marker field lets GC tell
+// trees apart from regular blobs.
+type casTree struct {
+	YagulTree int        `json:"yagulTree"`
+	Entries   []CASEntry `json:"entries"`
+}
+
+var casTreePrefix = []byte(`{"yagulTree":1,`)
+
+// OpenCAS opens the store at root, creating it if it doesn't exist.
+func OpenCAS(root string) (*CAS, error) {
+	for _, dir := range []string{"objects", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, wrapErr("mkdir", "", root, err)
+		}
+	}
+	return &CAS{root}, nil
+}
+
+func validDigest(digest string) error {
+	if len(digest) != sha256.Size*2 {
+		return fmt.Errorf("invalid digest %q: %w", digest, os.ErrInvalid)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", digest, os.ErrInvalid)
+	}
+	return nil
+}
+
+// Path returns where the blob with the given digest is (or would be) stored.
+// The digest must be a hex encoded SHA-256 digest, like the ones returned by
+// [CAS.Put]; Path returns "" for any other string.
+func (c *CAS) Path(digest string) string {
+	if validDigest(digest) != nil {
+		return ""
+	}
+	return filepath.Join(c.root, "objects", digest[:2], digest[2:4], digest)
+}
+
+// Put stores the content of r and returns it's hex encoded SHA-256 digest. If
+// the blob was already stored, it's not written again.
+func (c *CAS) Put(r io.Reader) (string, error) {
+	digest, err := c.put(r)
+	return digest, wrapErr("put", "", c.root, err)
+}
+
+func (c *CAS) put(r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Join(c.root, "tmp"), "put-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	dest := c.Path(digest)
+	exists, err := Exists(dest)
+	if err != nil || exists {
+		return digest, err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	// If another writer stored the same blob in the meantime, the rename
+	// replaces it with identical content.
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// Get opens the blob with the given digest. It returns an [os.ErrNotExist]
+// error if it's not stored.
+func (c *CAS) Get(digest string) (io.ReadCloser, error) {
+	f, err := c.get(digest)
+	if err != nil {
+		// Returning f would make the interface non-nil.
+		return nil, &Error{Op: "get", Source: c.root, Err: err}
+	}
+	return f, nil
+}
+
+func (c *CAS) get(digest string) (*os.File, error) {
+	if err := validDigest(digest); err != nil {
+		return nil, err
+	}
+	return os.Open(c.Path(digest))
+}
+
+// Has checks wether the blob with the given digest is stored.
+func (c *CAS) Has(digest string) (bool, error) {
+	if err := validDigest(digest); err != nil {
+		return false, &Error{Op: "has", Source: c.root, Err: err}
+	}
+	return Exists(c.Path(digest))
+}
+
+// Delete removes the blob with the given digest. Deleting a blob that isn't
+// stored is not an error.
+func (c *CAS) Delete(digest string) error {
+	if err := validDigest(digest); err != nil {
+		return &Error{Op: "delete", Source: c.root, Err: err}
+	}
+	err := os.Remove(c.Path(digest))
+	if err != nil && !os.IsNotExist(err) {
+		return &Error{Op: "delete", Source: c.root, Err: err}
+	}
+	return nil
+}
+
+// Digests returns the digests of every stored blob in no particular order.
+func (c *CAS) Digests() ([]string, error) {
+	var digests []string
+	err := filepath.WalkDir(filepath.Join(c.root, "objects"), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && validDigest(d.Name()) == nil {
+			digests = append(digests, d.Name())
+		}
+		return nil
+	})
+	return digests, wrapErr("walk", c.root, "", err)
+}
+
+// PutTree stores every file of the tree at dir and a blob describing the tree
+// (paths, modes and symlinks) and returns the digest of the latter. The tree
+// can be recreated with [CAS.Materialize] and [CAS.GC] keeps the files of a
+// tree as long as the tree is reachable.
+func (c *CAS) PutTree(dir string) (string, error) {
+	digest, err := c.putTree(dir)
+	return digest, wrapErr("put", dir, c.root, err)
+}
+
+func (c *CAS) putTree(dir string) (string, error) {
+	tree := casTree{YagulTree: 1}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		e := CASEntry{Path: filepath.ToSlash(rel), Mode: info.Mode()}
+		switch {
+		case d.IsDir():
+		case d.Type()&os.ModeSymlink != 0:
+			if e.Target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			e.Digest, err = c.put(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		tree.Entries = append(tree.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return "", err
+	}
+	return c.put(bytes.NewReader(data))
+}
+
+// ReadTree returns the entries of a tree stored with [CAS.PutTree]. It returns
+// an [os.ErrInvalid] error if the blob isn't a tree.
+func (c *CAS) ReadTree(digest string) ([]CASEntry, error) {
+	entries, err := c.readTreeEntries(digest)
+	return entries, wrapErr("read", c.root, "", err)
+}
+
+func (c *CAS) readTreeEntries(digest string) ([]CASEntry, error) {
+	entries, isTree, err := c.readTree(digest)
+	if err == nil && !isTree {
+		err = fmt.Errorf("blob %s is not a tree: %w", digest, os.ErrInvalid)
+	}
+	return entries, err
+}
+
+func (c *CAS) readTree(digest string) ([]CASEntry, bool, error) {
+	r, err := c.get(digest)
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.HasPrefix(data, casTreePrefix) {
+		return nil, false, nil
+	}
+	var tree casTree
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, false, err
+	}
+	return tree.Entries, true, nil
+}
+
+// Materialize recreates the tree with the given digest at dest (which must not
+// exist). If link is true, files are hard links to the blobs, which is instant
+// and takes no extra space but they share the read-only permissions of the
+// store (and they're copied anyway if dest is in another file system).
+// Otherwise files are copied and get the permissions they had when stored.
+func (c *CAS) Materialize(digest, dest string, link bool) error {
+	return wrapErr("materialize", c.root, dest, c.materialize(digest, dest, link))
+}
+
+func (c *CAS) materialize(digest, dest string, link bool) error {
+	entries, err := c.readTreeEntries(digest)
+	if err != nil {
+		return err
+	}
+	// Entries are checked before creating anything, so a tree can't write
+	// outside of dest, either directly or through one of it's symlinks.
+	symlinks := map[string]bool{}
+	for _, e := range entries {
+		if err := checkEntryPath(e.Path, symlinks); err != nil {
+			return err
+		}
+		if e.Mode&os.ModeSymlink != 0 {
+			symlinks[e.Path] = true
+		}
+	}
+	if err := os.Mkdir(dest, 0755); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dest, filepath.FromSlash(e.Path))
+		switch {
+		case e.Mode.IsDir():
+			err = os.Mkdir(path, e.Mode.Perm()|0700)
+		case e.Mode&os.ModeSymlink != 0:
+			err = os.Symlink(e.Target, path)
+		default:
+			err = c.materializeFile(e, path, link)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Directory permissions are restored at the end, deepest first, so
+	// read-only directories can be populated.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path > entries[j].Path })
+	for _, e := range entries {
+		if e.Mode.IsDir() {
+			if err := os.Chmod(filepath.Join(dest, filepath.FromSlash(e.Path)), e.Mode.Perm()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkEntryPath returns an error if p isn't a relative path below the root of
+// the tree or if it's inside one of the given symlinks.
+func checkEntryPath(p string, symlinks map[string]bool) error {
+	parts := strings.Split(p, "/")
+	if !filepath.IsLocal(filepath.FromSlash(p)) || p == "." {
+		return fmt.Errorf("invalid tree entry %q: %w", p, os.ErrInvalid)
+	}
+	for i, part := range parts {
+		if part == ".." {
+			return fmt.Errorf("invalid tree entry %q: %w", p, os.ErrInvalid)
+		}
+		if i > 0 && symlinks[strings.Join(parts[:i], "/")] {
+			return fmt.Errorf("invalid tree entry %q: it's inside a symlink: %w", p, os.ErrInvalid)
+		}
+	}
+	return nil
+}
+
+func (c *CAS) materializeFile(e CASEntry, path string, link bool) error {
+	if err := validDigest(e.Digest); err != nil {
+		return err
+	}
+	blob := c.Path(e.Digest)
+	if link {
+		err := os.Link(blob, path)
+		if err == nil || !isCrossDevice(err) {
+			return err
+		}
+	}
+	if _, err := CopyFile(blob, path); err != nil {
+		return err
+	}
+	return os.Chmod(path, e.Mode.Perm())
+}
+
+// GC removes every blob that isn't reachable from roots and returns how many
+// were removed. A blob is reachable if it's one of the roots or a file of a
+// reachable tree (see [CAS.PutTree]). Blobs stored while GC runs may be removed,
+// so it shouldn't run concurrently with Put.
+func (c *CAS) GC(roots ...string) (int, error) {
+	removed, err := c.gc(roots)
+	return removed, wrapErr("gc", c.root, "", err)
+}
+
+func (c *CAS) gc(roots []string) (int, error) {
+	reachable := map[string]bool{}
+	for _, root := range roots {
+		if err := validDigest(root); err != nil {
+			return 0, err
+		}
+		if reachable[root] {
+			continue
+		}
+		reachable[root] = true
+		entries, _, err := c.readTree(root)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+		for _, e := range entries {
+			if e.Digest != "" {
+				reachable[e.Digest] = true
+			}
+		}
+	}
+
+	digests, err := c.Digests()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, d := range digests {
+		if reachable[d] {
+			continue
+		}
+		if err := c.Delete(d); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,138 @@
+package fs_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestCAS(t *testing.T) {
+	store, err := fs.OpenCAS(filepath.Join(t.TempDir(), "cas"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := store.Put(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected digest %s", digest)
+	}
+	again, err := store.Put(strings.NewReader("hello"))
+	if err != nil || again != digest {
+		t.Errorf("expected the same digest, got %s (%v)", again, err)
+	}
+	r, err := store.Get(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(r)
+	r.Close()
+	if string(content) != "hello" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if has, _ := store.Has(digest); !has {
+		t.Error("expected the blob to be stored")
+	}
+	if _, err := store.Has("not a digest"); err == nil {
+		t.Error("expected an invalid digest to fail")
+	}
+
+	src := t.TempDir()
+	tree := fs.Fixture{
+		"a.txt":      fs.FileEntry("hello"),
+		"dup.txt":    fs.FileEntry("hello"),
+		"bin/run.sh": fs.FileEntry("#!/bin/sh").WithMode(0755),
+		"link":       fs.SymlinkEntry("a.txt"),
+		"empty/":     fs.DirEntry(),
+	}
+	if err := tree.Create(src); err != nil {
+		t.Fatal(err)
+	}
+	treeDigest, err := store.PutTree(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []bool{false, true} {
+		dest := filepath.Join(t.TempDir(), "out")
+		if err := store.Materialize(treeDigest, dest, link); err != nil {
+			t.Fatal(err)
+		}
+		want := fs.Fixture{}
+		for k, e := range tree {
+			if link && e.Mode != 0 {
+				// Hard links share the read-only permissions of the store.
+				e.Mode = 0444
+			}
+			want[k] = e
+		}
+		fs.AssertTree(t, dest, want)
+	}
+
+	orphan, err := store.Put(strings.NewReader("orphan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := store.GC(treeDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("expected only the orphan to be removed, %d blobs were removed", removed)
+	}
+	if has, _ := store.Has(orphan); has {
+		t.Error("the orphan blob was not removed")
+	}
+	if has, _ := store.Has(digest); !has {
+		t.Error("a blob reachable from the tree was removed")
+	}
+
+	if err := store.Delete(digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(digest); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestCASMaterializeEscape(t *testing.T) {
+	dir := t.TempDir()
+	store, err := fs.OpenCAS(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := store.Path("ab"); path != "" {
+		t.Errorf("expected no path for a short digest, got %q", path)
+	}
+	digest, err := store.Put(strings.NewReader("escaped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trees := map[string]string{
+		"parent":   `{"yagulTree":1,"entries":[{"path":"../escaped.txt","mode":420,"digest":"` + digest + `"}]}`,
+		"absolute": `{"yagulTree":1,"entries":[{"path":"` + filepath.ToSlash(filepath.Join(dir, "escaped.txt")) + `","mode":420,"digest":"` + digest + `"}]}`,
+		"symlink":  `{"yagulTree":1,"entries":[{"path":"link","mode":134218239,"target":".."},{"path":"link/escaped.txt","mode":420,"digest":"` + digest + `"}]}`,
+	}
+	for name, tree := range trees {
+		treeDigest, err := store.Put(strings.NewReader(tree))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Materialize(treeDigest, filepath.Join(dir, "out"), false)
+		if !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("%s: expected an invalid error, got %v", name, err)
+		}
+		if ok, _ := fs.Exists(filepath.Join(dir, "escaped.txt")); ok {
+			t.Fatalf("%s: the tree was materialized outside of dest", name)
+		}
+		os.RemoveAll(filepath.Join(dir, "out"))
+	}
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	errDestExists = fmt.Errorf("destination exists and will not be replaced: %w", os.ErrExist)
+	errSameFile   = fmt.Errorf("source and destination are the same file: %w", os.ErrInvalid)
+)
+
+// Error records a failed operation of this package and the paths involved,
+// like [os.PathError] and [os.LinkError] do for single system calls, so
+// callers can tell which path and which phase failed. Sentinel errors are
+// wrapped, so checks like errors.Is(err, os.ErrExist) keep working (unlike
+// [os.IsExist], which doesn't unwrap errors).
+//
+// Every function and method of this package that works on files returns it,
+// except:
+//   - The ones that only work on paths or text ([ExpandPath], [RelPath],
+//     [IsInside], [CommonAncestor], [ParseTree], [ParseTxtar]) and the XDG
+//     lookups ([ConfigHome], [AppDirs]...).
+//   - The test helpers ([Fixture], [ReadFixture], [AssertTree]).
+//   - The wrappers of single system calls ([GetXattr], [SetXattr],
+//     [ListXattr], [RemoveXattr], [FreeSpace]) and the methods of [Mapping],
+//     which return an [*os.PathError] like the os package does.
+type Error struct {
+	// Op is the phase that failed: "stat", "open", "create", "mkdir",
+	// "copy", "rename", "remove", "backup"... or the name of the whole
+	// operation (eg: "copy", "move", "join") if it was rejected before
+	// starting it.
+	Op string
+	// Operations with a single path leave Source or Dest empty.
+	Source string
+	Dest   string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := e.Op
+	if e.Source != "" {
+		msg += " " + e.Source
+	}
+	if e.Dest != "" {
+		if e.Source != "" {
+			msg += " ->"
+		}
+		msg += " " + e.Dest
+	}
+
+	// System errors on Source or Dest would repeat them, but the ones on other
+	// paths (eg: a chunk of a join or a file inside a copied directory) keep
+	// them.
+	err := e.Err
+	switch sysErr := err.(type) {
+	case *os.PathError:
+		if e.isOwnPath(sysErr.Path) {
+			err = sysErr.Err
+		}
+	case *os.LinkError:
+		if e.isOwnPath(sysErr.Old) && e.isOwnPath(sysErr.New) {
+			err = sysErr.Err
+		}
+	}
+	return msg + ": " + err.Error()
+}
+
+func (e *Error) isOwnPath(path string) bool {
+	return path == e.Source || path == e.Dest
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// wrapErr returns err as an [*Error], unless it's nil or it already has one
+// (eg: returned by a nested copy), which is returned as is.
+func wrapErr(op, source, dest string, err error) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &Error{Op: op, Source: source, Dest: dest, Err: err}
+}
@@ -0,0 +1,131 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestError(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/a.txt":      fs.FileEntry("a"),
+		"src/sub/b.txt":  fs.FileEntry("b"),
+		"dest/sub/b.txt": fs.FileEntry("old"),
+		"existing.txt":   fs.FileEntry("existing"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := func(p string) string { return filepath.Join(root, p) }
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		op     string
+		source string
+		dest   string
+		is     error
+	}{
+		{
+			name: "missing source",
+			err:  second(fs.CopyFile(path("missing"), path("copy"))),
+			op:   "stat", source: path("missing"), dest: path("copy"), is: os.ErrNotExist,
+		},
+		{
+			name: "existing dest",
+			err:  second(fs.CopyFile(path("src/a.txt"), path("existing.txt"))),
+			op:   "copy", source: path("src/a.txt"), dest: path("existing.txt"), is: os.ErrExist,
+		},
+		{
+			name: "directory as file",
+			err:  second(fs.CopyFile(path("src"), path("copy"))),
+			op:   "copy", source: path("src"), dest: path("copy"), is: os.ErrInvalid,
+		},
+		{
+			name: "merge without overwrite",
+			err:  fs.CopyDirWithOptions(path("src"), path("dest"), fs.CopyOptions{}),
+			op:   "copy", source: path("src"), dest: path("dest"), is: os.ErrExist,
+		},
+		{
+			name: "move over existing",
+			err:  fs.Move(path("src/a.txt"), path("existing.txt")),
+			op:   "move", source: path("src/a.txt"), dest: path("existing.txt"), is: os.ErrExist,
+		},
+		{
+			name: "missing manifest",
+			err:  fs.Join(path("missing.manifest"), path("joined")),
+			op:   "join", source: path("missing.manifest"), dest: path("joined"), is: os.ErrNotExist,
+		},
+		{
+			name: "find up",
+			err:  second(fs.FindUp(path("src/sub"), "missing.txt")),
+			op:   "findup", source: path("src/sub"), is: os.ErrNotExist,
+		},
+		{
+			name: "usage of missing",
+			err:  second(fs.Usage(path("missing"))),
+			op:   "lstat", source: path("missing"), is: os.ErrNotExist,
+		},
+		{
+			name: "remove protected",
+			err:  fs.RemoveAll(string(filepath.Separator)),
+			op:   "remove", source: string(filepath.Separator), is: fs.ErrProtected,
+		},
+	} {
+		var e *fs.Error
+		if !errors.As(tc.err, &e) {
+			t.Errorf("%s: expected an *fs.Error, got %#v", tc.name, tc.err)
+			continue
+		}
+		if e.Op != tc.op || e.Source != tc.source || e.Dest != tc.dest {
+			t.Errorf("%s: expected %s %s -> %s, got %s %s -> %s", tc.name, tc.op, tc.source, tc.dest, e.Op, e.Source, e.Dest)
+		}
+		if !errors.Is(tc.err, tc.is) {
+			t.Errorf("%s: expected %v to be %v", tc.name, tc.err, tc.is)
+		}
+	}
+
+	_, err = fs.CopyFile(path("src/sub/b.txt"), path("dest/sub/b.txt"))
+	want := "copy " + path("src/sub/b.txt") + " -> " + path("dest/sub/b.txt") + ": destination exists and will not be replaced: file already exists"
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestErrorNestedPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := fs.Split(path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := path + ".part0002"
+	if err := os.Remove(chunk); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "joined.bin")
+	err = fs.Join(manifest, dest)
+	var e *fs.Error
+	if !errors.As(err, &e) || e.Source != manifest || e.Dest != dest {
+		t.Fatalf("expected an *fs.Error of the join, got %v", err)
+	}
+	if !strings.Contains(err.Error(), chunk) {
+		t.Errorf("expected the missing chunk in %q", err.Error())
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v to be %v", err, os.ErrNotExist)
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,175 @@
+package fs
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/n-mou/yagul/itertools"
+)
+
+// FindUpOptions tunes [FindUpWithOptions] and [FindUpAll]. The zero value
+// searches up to the root of the file system.
+type FindUpOptions struct {
+	// StopAt has directories where the search stops after checking them.
+	StopAt []string
+	// StopAtHome stops the search after checking the home directory of the
+	// current user.
+	StopAtHome bool
+	// StopAtMarkers stops the search after checking the first directory that
+	// contains any of these names (eg: ".git" to stay inside a repository).
+	StopAtMarkers []string
+	// SameDevice stops the search at mount points, so directories in other
+	// file systems aren't checked. It's ignored on platforms without device
+	// numbers (eg: Windows).
+	SameDevice bool
+}
+
+// FindUp looks for any of names in start and in each of it's parents up to the
+// root of the file system and returns the path of the first one found (eg: the
+// go.mod of the module a directory belongs to). Names are checked in order in
+// each directory, so they can be used as fallbacks. It returns an
+// [os.ErrNotExist] error if none is found.
+func FindUp(start string, names ...string) (string, error) {
+	return FindUpWithOptions(start, FindUpOptions{}, names...)
+}
+
+// FindUpWithOptions does the same as [FindUp] but the search can stop before
+// the root of the file system, as set by opts.
+func FindUpWithOptions(start string, opts FindUpOptions, names ...string) (string, error) {
+	for match, err := range FindUpAll(start, opts, names...) {
+		return match, err
+	}
+	return "", &Error{Op: "findup", Source: start, Err: fmt.Errorf("%v not found in it nor it's parents: %w", names, os.ErrNotExist)}
+}
+
+// FindUpAll returns an iterator over every match of [FindUpWithOptions], from
+// the closest to start to the farthest one (eg: to merge config files of nested
+// projects). If a directory can't be checked, the error is yielded once and the
+// iteration stops.
+func FindUpAll(start string, opts FindUpOptions, names ...string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		itertools.PullToPush2(&upFinder{start: start, opts: opts, names: names})(yield)
+	}
+}
+
+// upFinder is a PullIterator2 that checks a directory each time it runs out of
+// matches.
+type upFinder struct {
+	start string
+	opts  FindUpOptions
+	names []string
+
+	dir     string
+	stopAt  []os.FileInfo
+	device  fileKey
+	matches []string
+	started bool
+	done    bool
+}
+
+func (f *upFinder) Next() (string, error, bool) {
+	if f.done {
+		return "", nil, false
+	}
+	if !f.started {
+		f.started = true
+		if err := f.init(); err != nil {
+			f.done = true
+			return "", wrapErr("findup", f.start, "", err), true
+		}
+	}
+
+	for len(f.matches) == 0 {
+		if f.dir == "" {
+			f.done = true
+			return "", nil, false
+		}
+		if err := f.check(); err != nil {
+			f.done = true
+			return "", wrapErr("findup", f.start, "", err), true
+		}
+	}
+	match := f.matches[0]
+	f.matches = f.matches[1:]
+	return match, nil, true
+}
+
+func (f *upFinder) Stop() {
+	f.done = true
+}
+
+func (f *upFinder) init() error {
+	var err error
+	if f.dir, err = filepath.Abs(f.start); err != nil {
+		return err
+	}
+	stopAt := f.opts.StopAt
+	if f.opts.StopAtHome {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		stopAt = append(stopAt[:len(stopAt):len(stopAt)], home)
+	}
+	for _, dir := range stopAt {
+		info, err := os.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		f.stopAt = append(f.stopAt, info)
+	}
+	return nil
+}
+
+// check collects the matches in f.dir and moves f.dir to it's parent, or to ""
+// if the search is over.
+func (f *upFinder) check() error {
+	info, err := os.Stat(f.dir)
+	if err != nil {
+		return err
+	}
+	if f.opts.SameDevice {
+		key, _, ok := inodeOf(info)
+		if ok && f.device != (fileKey{}) && key.dev != f.device.dev {
+			f.dir = ""
+			return nil
+		}
+		f.device = key
+	}
+
+	for _, name := range f.names {
+		path := filepath.Join(f.dir, name)
+		exists, err := Lexists(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			f.matches = append(f.matches, path)
+		}
+	}
+
+	last := false
+	for _, stop := range f.stopAt {
+		last = last || os.SameFile(info, stop)
+	}
+	for _, marker := range f.opts.StopAtMarkers {
+		exists, err := Lexists(filepath.Join(f.dir, marker))
+		if err != nil {
+			return err
+		}
+		last = last || exists
+	}
+
+	parent := filepath.Dir(f.dir)
+	if last || parent == f.dir {
+		f.dir = ""
+	} else {
+		f.dir = parent
+	}
+	return nil
+}
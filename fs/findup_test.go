@@ -0,0 +1,60 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestFindUp(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"go.mod":                      fs.FileEntry("module outer"),
+		"repo/.git/":                  fs.DirEntry(),
+		"repo/go.mod":                 fs.FileEntry("module repo"),
+		"repo/cmd/tool/.myconfig.yml": fs.FileEntry(""),
+		"repo/cmd/tool/sub/":          fs.DirEntry(),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := filepath.Join(root, "repo/cmd/tool/sub")
+
+	got, err := fs.FindUp(start, "go.mod")
+	if err != nil || got != filepath.Join(root, "repo/go.mod") {
+		t.Errorf("expected repo/go.mod, got %s (%v)", got, err)
+	}
+	got, err = fs.FindUp(start, ".myconfig.yaml", ".myconfig.yml", "go.mod")
+	if err != nil || got != filepath.Join(root, "repo/cmd/tool/.myconfig.yml") {
+		t.Errorf("expected the config file, got %s (%v)", got, err)
+	}
+	if _, err := fs.FindUp(start, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+
+	collect := func(opts fs.FindUpOptions, names ...string) []string {
+		var matches []string
+		for match, err := range fs.FindUpAll(start, opts, names...) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, _ := filepath.Rel(root, match)
+			matches = append(matches, filepath.ToSlash(rel))
+		}
+		return matches
+	}
+	if got := collect(fs.FindUpOptions{}, "go.mod"); !slices.Equal(got, []string{"repo/go.mod", "go.mod"}) {
+		t.Errorf("unexpected matches %v", got)
+	}
+	if got := collect(fs.FindUpOptions{StopAtMarkers: []string{".git"}}, "go.mod"); !slices.Equal(got, []string{"repo/go.mod"}) {
+		t.Errorf("unexpected matches stopping at .git %v", got)
+	}
+	opts := fs.FindUpOptions{StopAt: []string{filepath.Join(root, "repo/cmd")}, SameDevice: true}
+	if got := collect(opts, "go.mod", ".myconfig.yml"); !slices.Equal(got, []string{"repo/cmd/tool/.myconfig.yml"}) {
+		t.Errorf("unexpected matches stopping at repo/cmd %v", got)
+	}
+}
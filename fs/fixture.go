@@ -0,0 +1,398 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FixtureEntry describes a file, directory or symlink of a [Fixture]. Use
+// [FileEntry], [DirEntry] and [SymlinkEntry] to create them.
+type FixtureEntry struct {
+	Dir     bool
+	Content string
+	Target  string // Target of the symlink, empty if it's not a symlink
+	// Mode is the permission bits of the entry. If it's 0, files are
+	// created with 0644 and directories with 0755 and it's not checked by
+	// [Fixture.Diff].
+	Mode os.FileMode
+	// ModTime is the modification time of the entry. If it's zero, it's
+	// left untouched and it's not checked by [Fixture.Diff].
+	ModTime time.Time
+}
+
+// FileEntry returns a regular file entry with the given content.
+func FileEntry(content string) FixtureEntry {
+	return FixtureEntry{Content: content}
+}
+
+// DirEntry returns a directory entry. Directories holding other entries are
+// created anyway, so it's only needed for empty directories or to set it's
+// mode or mtime.
+func DirEntry() FixtureEntry {
+	return FixtureEntry{Dir: true}
+}
+
+// SymlinkEntry returns a symlink entry pointing to target.
+func SymlinkEntry(target string) FixtureEntry {
+	return FixtureEntry{Target: target}
+}
+
+// WithMode returns a copy of e with the given permissions.
+func (e FixtureEntry) WithMode(mode os.FileMode) FixtureEntry {
+	e.Mode = mode
+	return e
+}
+
+// WithModTime returns a copy of e with the given modification time.
+func (e FixtureEntry) WithModTime(t time.Time) FixtureEntry {
+	e.ModTime = t
+	return e
+}
+
+// Fixture is a declarative description of a directory tree meant for tests.
+// Keys are paths relative to the root of the tree using forward slashes, so
+// instead of a dozen os.MkdirAll and os.WriteFile calls a tree can be declared
+// like this:
+//
+//	tree := fs.Fixture{
+//		"go.mod":          fs.FileEntry("module example"),
+//		"cmd/run.sh":      fs.FileEntry("#!/bin/sh").WithMode(0755),
+//		"cmd/latest":      fs.SymlinkEntry("run.sh"),
+//		"testdata/empty/": fs.DirEntry(),
+//	}
+//	err := tree.Create(t.TempDir())
+//
+// A trailing slash in a key also marks the entry as a directory.
+type Fixture map[string]FixtureEntry
+
+// Create creates the entries of f inside root (which is created if it
+// doesn't exist). Parent directories are created automatically, the modes of
+// directories are applied once every entry has been created (so read-only
+// directories can be declared) and mtimes are applied at the end since creating
+// an entry changes the mtime of it's directory.
+func (f Fixture) Create(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	paths := f.paths()
+
+	for _, p := range paths {
+		e := f.entry(p)
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+
+		switch {
+		case e.Dir:
+			if err := os.MkdirAll(full, 0755); err != nil {
+				return err
+			}
+		case e.Target != "":
+			if err := os.Symlink(e.Target, full); err != nil {
+				return err
+			}
+		default:
+			mode := e.Mode
+			if mode == 0 {
+				mode = 0644
+			}
+			if err := os.WriteFile(full, []byte(e.Content), mode); err != nil {
+				return err
+			}
+			// WriteFile is affected by the umask, set the exact mode.
+			if err := os.Chmod(full, mode); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Deepest entries go first so changing a directory doesn't affect it's
+	// children.
+	for i := len(paths) - 1; i >= 0; i-- {
+		e := f.entry(paths[i])
+		full := filepath.Join(root, filepath.FromSlash(paths[i]))
+		if e.Dir && e.Mode != 0 {
+			if err := os.Chmod(full, e.Mode); err != nil {
+				return err
+			}
+		}
+		if !e.ModTime.IsZero() && e.Target == "" {
+			if err := os.Chtimes(full, e.ModTime, e.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// paths returns the normalized keys of f sorted so parents go before their
+// children.
+func (f Fixture) paths() []string {
+	paths := make([]string, 0, len(f))
+	for k := range f {
+		paths = append(paths, strings.TrimSuffix(k, "/"))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// entry returns the entry of a normalized path, no matter if it's key had a
+// trailing slash.
+func (f Fixture) entry(p string) FixtureEntry {
+	if e, ok := f[p]; ok {
+		return e
+	}
+	e := f[p+"/"]
+	e.Dir = true
+	return e
+}
+
+// ReadFixture does the opposite of [Fixture.Create], it reads the tree at root
+// and returns it's description with the content, mode and mtime of each entry.
+func ReadFixture(root string) (Fixture, error) {
+	f := Fixture{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		e := FixtureEntry{Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+		switch {
+		case d.IsDir():
+			e.Dir = true
+		case d.Type()&os.ModeSymlink != 0:
+			if e.Target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		default:
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			e.Content = string(content)
+		}
+		f[filepath.ToSlash(rel)] = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Diff compares the tree at root with f and returns a readable report of the
+// differences, one per line, or an empty string if the tree matches. Lines
+// start with "-" for entries missing on disk, "+" for unexpected entries and
+// "~" for entries that differ.
+func (f Fixture) Diff(root string) (string, error) {
+	got, err := ReadFixture(root)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, p := range f.paths() {
+		want := f.entry(p)
+		have, ok := got[p]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("- %s (missing)", p))
+			continue
+		}
+		delete(got, p)
+		lines = append(lines, diffEntry(p, want, have)...)
+	}
+	for _, p := range got.paths() {
+		if isFixtureAncestor(f, p) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("+ %s (unexpected %s)", p, got[p].kind()))
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// isFixtureAncestor reports wether p is an implicit parent directory of any
+// entry of f.
+func isFixtureAncestor(f Fixture, p string) bool {
+	for k := range f {
+		if strings.HasPrefix(k, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (e FixtureEntry) kind() string {
+	switch {
+	case e.Dir:
+		return "directory"
+	case e.Target != "":
+		return "symlink"
+	default:
+		return "file"
+	}
+}
+
+func diffEntry(p string, want, have FixtureEntry) []string {
+	var lines []string
+	if want.kind() != have.kind() {
+		return []string{fmt.Sprintf("~ %s: expected %s, got %s", p, want.kind(), have.kind())}
+	}
+	if want.Target != have.Target {
+		lines = append(lines, fmt.Sprintf("~ %s: expected link to %q, got %q", p, want.Target, have.Target))
+	}
+	if want.kind() == "file" && want.Content != have.Content {
+		lines = append(lines, fmt.Sprintf("~ %s: content differs\n%s", p, contentDiff(want.Content, have.Content)))
+	}
+	if want.Mode != 0 && want.Mode != have.Mode {
+		lines = append(lines, fmt.Sprintf("~ %s: expected mode %v, got %v", p, want.Mode, have.Mode))
+	}
+	if !want.ModTime.IsZero() && !want.ModTime.Equal(have.ModTime) {
+		lines = append(lines, fmt.Sprintf("~ %s: expected mtime %v, got %v", p, want.ModTime, have.ModTime))
+	}
+	return lines
+}
+
+// contentDiff shows the first line where want and have differ with some
+// indentation so it stands out in the report.
+func contentDiff(want, have string) string {
+	wantLines := strings.Split(want, "\n")
+	haveLines := strings.Split(have, "\n")
+	i := 0
+	for i < len(wantLines) && i < len(haveLines) && wantLines[i] == haveLines[i] {
+		i++
+	}
+	line := func(lines []string) string {
+		if i >= len(lines) {
+			return "<EOF>"
+		}
+		return strconv.Quote(lines[i])
+	}
+	return fmt.Sprintf("    line %d:\n    expected: %s\n    got:      %s", i+1, line(wantLines), line(haveLines))
+}
+
+// TestingT is the subset of [testing.TB] used by [AssertTree], so this package
+// doesn't depend on the testing package.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertTree fails the test if the tree at root doesn't match want, printing
+// the differences reported by [Fixture.Diff].
+func AssertTree(t TestingT, root string, want Fixture) {
+	t.Helper()
+	diff, err := want.Diff(root)
+	if err != nil {
+		t.Errorf("reading %s: %v", root, err)
+		return
+	}
+	if diff != "" {
+		t.Errorf("%s doesn't match the expected tree:\n%s", root, diff)
+	}
+}
+
+// ParseTxtar reads a fixture written in the txtar format (the one used by the
+// Go tool tests). Each file starts with a "-- name --" header line and it's
+// content is everything up to the next header. Names ending with a slash are
+// directories and the header can hold extra attributes after the name:
+//
+//	-- run.sh mode=0755 --
+//	#!/bin/sh
+//	-- latest -> run.sh --
+//	-- old.txt mtime=2024-01-02T15:04:05Z --
+//	-- empty/ --
+//
+// Since attributes are separated by spaces, names with spaces aren't supported.
+// Any text before the first header is a comment and it's ignored.
+func ParseTxtar(data []byte) (Fixture, error) {
+	f := Fixture{}
+	var name string
+	var entry FixtureEntry
+	var content bytes.Buffer
+	inFile := false
+
+	flush := func() {
+		if !inFile {
+			return
+		}
+		if !entry.Dir && entry.Target == "" {
+			entry.Content = content.String()
+		}
+		f[name] = entry
+	}
+
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		header := strings.TrimRight(string(line), "\r\n")
+		if !strings.HasPrefix(header, "-- ") || !strings.HasSuffix(header, " --") || len(header) < 6 {
+			if inFile {
+				content.Write(line)
+			}
+			continue
+		}
+
+		flush()
+		var err error
+		name, entry, err = parseTxtarHeader(header[3 : len(header)-3])
+		if err != nil {
+			return nil, err
+		}
+		content.Reset()
+		inFile = true
+	}
+	flush()
+	return f, nil
+}
+
+func parseTxtarHeader(header string) (string, FixtureEntry, error) {
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return "", FixtureEntry{}, fmt.Errorf("txtar header without name: %w", os.ErrInvalid)
+	}
+	name := fields[0]
+	e := FixtureEntry{Dir: strings.HasSuffix(name, "/")}
+
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "->" && i+1 < len(fields):
+			e.Target = fields[i+1]
+			i++
+		case strings.HasPrefix(field, "mode="):
+			mode, err := strconv.ParseUint(field[len("mode="):], 8, 32)
+			if err != nil {
+				return "", FixtureEntry{}, fmt.Errorf("invalid mode in %q: %w", header, err)
+			}
+			e.Mode = os.FileMode(mode)
+		case strings.HasPrefix(field, "mtime="):
+			t, err := time.Parse(time.RFC3339, field[len("mtime="):])
+			if err != nil {
+				return "", FixtureEntry{}, fmt.Errorf("invalid mtime in %q: %w", header, err)
+			}
+			e.ModTime = t
+		default:
+			return "", FixtureEntry{}, fmt.Errorf("unknown attribute %q in %q: %w", field, header, os.ErrInvalid)
+		}
+	}
+	return name, e, nil
+}
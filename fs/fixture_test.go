@@ -0,0 +1,92 @@
+package fs_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+const testTxtar = `Files copied by TestCopyDirFixture.
+-- go.mod --
+module example
+-- cmd/run.sh mode=0755 --
+#!/bin/sh
+echo hi
+-- cmd/old.txt mtime=2024-01-02T15:04:05Z --
+-- empty/ --
+`
+
+func TestCopyDirFixture(t *testing.T) {
+	fixture, err := fs.ParseTxtar([]byte(testTxtar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "src")
+	if err := fixture.Create(src); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, src, fixture)
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := fs.CopyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	// CopyFile doesn't preserve modes nor mtimes, only contents are compared.
+	fs.AssertTree(t, dst, fs.Fixture{
+		"go.mod":      fs.FileEntry("module example\n"),
+		"cmd/run.sh":  fs.FileEntry("#!/bin/sh\necho hi\n"),
+		"cmd/old.txt": fs.FileEntry(""),
+		"empty/":      fs.DirEntry(),
+	})
+}
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertTreeDiff(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"a.txt":     fs.FileEntry("one\ntwo\n"),
+		"extra.txt": fs.FileEntry(""),
+		"link":      fs.SymlinkEntry("a.txt"),
+		"dir/":      fs.DirEntry().WithMode(0700),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeT{}
+	fs.AssertTree(ft, root, fs.Fixture{
+		"a.txt":       fs.FileEntry("one\nthree\n"),
+		"link":        fs.SymlinkEntry("b.txt"),
+		"dir":         fs.DirEntry().WithMode(0755),
+		"missing.txt": fs.FileEntry("").WithModTime(time.Now()),
+	})
+	if len(ft.errors) != 1 {
+		t.Fatalf("expected a single error, got %v", ft.errors)
+	}
+	report := ft.errors[0]
+	for _, want := range []string{
+		`~ a.txt: content differs`,
+		`expected: "three"`,
+		`~ dir: expected mode -rwxr-xr-x, got -rwx------`,
+		`~ link: expected link to "b.txt", got "a.txt"`,
+		`- missing.txt (missing)`,
+		`+ extra.txt (unexpected file)`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/n-mou/yagul/itertools"
+)
+
+// FollowOptions tunes [Follow]. The zero value starts at the end of the file
+// and checks for new data 4 times per second.
+type FollowOptions struct {
+	// Lines is the number of lines from the end of the file yielded before
+	// waiting for new ones, like the -n flag of tail.
+	Lines int
+	// FromStart yields the whole file before waiting for new lines. It takes
+	// precedence over Lines.
+	FromStart bool
+	// Poll is how often the file is checked for new data, truncation and
+	// rotation. If it's 0, it's checked every 250ms.
+	Poll time.Duration
+}
+
+// follower is a PullIterator2 whose Next blocks until a new line is written.
+type follower struct {
+	ctx  context.Context
+	path string
+	opts FollowOptions
+
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	pending string // Part of a line whose end hasn't been written yet
+	started bool
+	done    bool
+}
+
+// Follow returns an iterator over the lines appended to the file at path
+// behaving like "tail -F": it starts at the end of the file (or
+// [FollowOptions.Lines] lines before it) and yields every new line as it's
+// written, waiting for new ones when it reaches the end. If the file is
+// truncated it starts reading again from the beginning and, if it's rotated
+// (path points to a new file, like logrotate does), it finishes reading the old
+// file and switches to the new one. If path doesn't exist, it waits until it's
+// created.
+//
+// The iteration only ends when ctx is cancelled, when the loop breaks or after
+// yielding an error that can't be recovered from (eg: lack of permissions).
+// Lines are yielded without their line endings and a line isn't yielded until
+// it's ending has been written.
+func Follow(ctx context.Context, path string, opts FollowOptions) iter.Seq2[string, error] {
+	if opts.Poll <= 0 {
+		opts.Poll = 250 * time.Millisecond
+	}
+	return func(yield func(string, error) bool) {
+		itertools.PullToPush2(&follower{ctx: ctx, path: path, opts: opts})(yield)
+	}
+}
+
+func (f *follower) Next() (string, error, bool) {
+	if f.done || f.ctx.Err() != nil {
+		f.Stop()
+		return "", nil, false
+	}
+	if !f.started {
+		if err := f.start(); err != nil {
+			return f.fail(err)
+		}
+		if f.done {
+			return "", nil, false
+		}
+		f.started = true
+	}
+
+	for {
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		if err == nil {
+			line := strings.TrimSuffix(f.pending+chunk, "\n")
+			f.pending = ""
+			return strings.TrimSuffix(line, "\r"), nil, true
+		}
+		if !errors.Is(err, io.EOF) {
+			return f.fail(err)
+		}
+		f.pending += chunk
+
+		rotated, err := f.checkFile()
+		if err != nil {
+			return f.fail(err)
+		}
+		if rotated && f.pending != "" {
+			// The old file ended without a line ending, it's last line won't
+			// be completed.
+			line := f.pending
+			f.pending = ""
+			return line, nil, true
+		}
+		if !rotated && !f.wait() {
+			return "", nil, false
+		}
+	}
+}
+
+func (f *follower) Stop() {
+	f.done = true
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+func (f *follower) fail(err error) (string, error, bool) {
+	f.Stop()
+	return "", wrapErr("follow", f.path, "", err), true
+}
+
+// wait sleeps until the next poll. It returns false if ctx was cancelled.
+func (f *follower) wait() bool {
+	timer := time.NewTimer(f.opts.Poll)
+	defer timer.Stop()
+	select {
+	case <-f.ctx.Done():
+		f.Stop()
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// start opens the file for the first time, waiting for it to be created, and
+// moves to the initial position.
+func (f *follower) start() error {
+	for {
+		err := f.open()
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if !f.wait() {
+			return nil
+		}
+	}
+
+	var err error
+	switch {
+	case f.opts.FromStart:
+		f.offset = 0
+	case f.opts.Lines > 0:
+		f.offset, err = tailOffset(f.file, f.info.Size(), f.opts.Lines)
+	default:
+		f.offset = f.info.Size()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.file.Seek(f.offset, io.SeekStart)
+	return err
+}
+
+func (f *follower) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, f.info = file, info
+	f.reader = bufio.NewReader(file)
+	f.offset = 0
+	return nil
+}
+
+// checkFile is called when the end of the file is reached. It reopens the file
+// if it was rotated (returning true) and goes back to the start if it was
+// truncated.
+func (f *follower) checkFile() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Rotated but the new file isn't there yet, keep waiting.
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !os.SameFile(f.info, info) {
+		if err := f.open(); err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	if info.Size() < f.offset {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		f.reader.Reset(f.file)
+		f.offset = 0
+		f.pending = ""
+	}
+	return false, nil
+}
+
+// tailOffset returns the offset where the last n lines of f start, reading it
+// backwards in blocks so big files aren't read entirely.
+func tailOffset(f *os.File, size int64, n int) (int64, error) {
+	const blockSize = 4096
+	buf := make([]byte, blockSize)
+	end := size
+	// A line ending at the end of the file doesn't start a new line.
+	skipLast := true
+
+	for end > 0 {
+		start := max(end-blockSize, 0)
+		block := buf[:end-start]
+		if _, err := f.ReadAt(block, start); err != nil {
+			return 0, err
+		}
+		for i := len(block) - 1; i >= 0; i-- {
+			if block[i] != '\n' {
+				skipLast = false
+				continue
+			}
+			if skipLast {
+				skipLast = false
+				continue
+			}
+			n--
+			if n == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
@@ -0,0 +1,82 @@
+package fs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old 1\nold 2\nold 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	appendLog := func(s string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		opts := fs.FollowOptions{Lines: 2, Poll: 5 * time.Millisecond}
+		for line, err := range fs.Follow(ctx, path, opts) {
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			lines <- line
+		}
+	}()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	expect("old 2")
+	expect("old 3")
+
+	appendLog("new ")
+	appendLog("1\r\n")
+	expect("new 1")
+
+	// Truncation starts over from the beginning.
+	if err := os.WriteFile(path, []byte("truncated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect("truncated")
+
+	// Rotation finishes the old file and switches to the new one.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog("rotated\n")
+	expect("rotated")
+
+	cancel()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Error("expected the iteration to end after cancelling")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the iteration didn't end after cancelling")
+	}
+}
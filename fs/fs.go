@@ -3,6 +3,7 @@
 package fs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -29,99 +30,279 @@ func Exists(path string) (bool, error) {
 		if os.IsNotExist(err) {
 			return false, nil
 		}
-		return false, err
+		return false, wrapErr("stat", path, "", err)
 	}
 	return true, nil
 }
 
+// CopyOptions tunes [CopyFileWithOptions], [CopyDirWithOptions] and
+// [MoveWithOptions]. The zero value behaves like [CopyFile], [CopyDir] and
+// [Move], refusing to replace existing destinations.
+type CopyOptions struct {
+	// Overwrite replaces existing destinations instead of returning an
+	// [os.ErrExist] error. Directories are merged: their contents are copied
+	// into the existing directory replacing the files with the same name. A
+	// directory and a file don't replace each other (it's still an
+	// [os.ErrExist] error) unless Backup is set.
+	Overwrite bool
+	// Backup renames the entries about to be replaced instead of destroying
+	// them, like the --backup flag of GNU cp. It only has effect with
+	// Overwrite.
+	Backup BackupOptions
+	// Xattrs copies the extended attributes of files and directories that
+	// hold user data, security labels and ACLs (user.*, security.* and
+	// system.posix_acl_*). Attributes that can't be copied don't stop the copy,
+	// they're reported at the end with an [*XattrError]. It's only supported on
+	// Linux, elsewhere it has no effect.
+	Xattrs bool
+	// Limiter throttles the reads of the copied files, it can be shared by
+	// several copies to limit their total throughput (see [NewLimiter]).
+	Limiter *Limiter
+	// CheckSpace checks that the file system of dest has enough free space
+	// for the whole copy before starting it, failing with a [*SpaceError]
+	// otherwise. Replaced files aren't taken into account and it's only
+	// supported where [FreeSpace] is.
+	CheckSpace bool
+	// Quota limits the bytes and files written, the copy fails with a
+	// [*QuotaError] when it runs out. It can be shared by several copies.
+	Quota *Quota
+}
+
 // CopyFile copies source file to the dest path. It relies in [io.Copy] in the
 // background so it works with files of any size (it doesn't load all of the
 // file bytes at once in memory so it won't crash with files that weight several
 // GBs). If source is a directory, CopyFile returns an [os.ErrInvalid] error and if
 // dest exists, it returns an [os.ErrExist] error instead of overwriting it. Any
 // other errors returned by the functions used inside will also be propagated.
+// Errors are returned as an [*Error] with the phase that failed.
 func CopyFile(source, dest string) (int64, error) {
+	return CopyFileWithOptions(source, dest, CopyOptions{})
+}
+
+// CopyFileWithOptions does the same as [CopyFile] but, if opts allow it, it
+// replaces dest (backing it up first if [CopyOptions.Backup] is set). Copying a
+// file over itself returns an [os.ErrInvalid] error instead of truncating it.
+func CopyFileWithOptions(source, dest string, opts CopyOptions) (int64, error) {
 	srcInfo, err := os.Stat(source)
 	if err != nil {
-		return 0, err
+		return 0, &Error{Op: "stat", Source: source, Dest: dest, Err: err}
 	}
 	if srcInfo.IsDir() {
-		return 0, fmt.Errorf("%s is a directory: %w", source, os.ErrInvalid)
+		return 0, &Error{Op: "copy", Source: source, Dest: dest, Err: fmt.Errorf("source is a directory: %w", os.ErrInvalid)}
 	}
 
 	srcFile, err := os.Open(source)
 	if err != nil {
-		return 0, err
+		return 0, &Error{Op: "open", Source: source, Dest: dest, Err: err}
 	}
 	defer srcFile.Close()
 
-	dstExists, err := Exists(dest)
+	if opts.CheckSpace {
+		if err := checkSpace(dest, uint64(srcInfo.Size())); err != nil {
+			return 0, wrapErr("space", source, dest, err)
+		}
+	}
+
+	err = prepareDest(srcInfo, source, dest, opts)
 	if err != nil {
 		return 0, err
 	}
-	if dstExists {
-		return 0, fmt.Errorf("%s exists and will not be replaced: %w", dest, os.ErrExist)
+	if opts.Quota != nil {
+		if err := opts.Quota.addFile(source); err != nil {
+			return 0, &Error{Op: "quota", Source: source, Dest: dest, Err: err}
+		}
 	}
 
 	dstFile, err := os.Create(dest)
 	if err != nil {
-		return 0, err
+		if opts.Quota != nil {
+			opts.Quota.release(1, 0)
+		}
+		return 0, &Error{Op: "create", Source: source, Dest: dest, Err: err}
 	}
 	defer dstFile.Close()
 
-	n, err := io.Copy(dstFile, srcFile)
+	var src io.Reader = srcFile
+	if opts.Limiter != nil {
+		src = NewThrottledReader(srcFile, opts.Limiter)
+	}
+	var dst io.Writer = dstFile
+	if opts.Quota != nil {
+		dst = &quotaWriter{dstFile, opts.Quota, source}
+	}
+	n, err := io.Copy(dst, src)
+	var quotaErr *QuotaError
+	if errors.As(err, &quotaErr) {
+		// Don't leave a truncated file behind, nor charge the quota for it.
+		dstFile.Close()
+		os.Remove(dest)
+		opts.Quota.release(1, n)
+		return 0, &Error{Op: "quota", Source: source, Dest: dest, Err: err}
+	}
 	if err != nil {
-		return 0, err
+		return 0, &Error{Op: "copy", Source: source, Dest: dest, Err: err}
 	}
 
+	if opts.Xattrs {
+		if failures := copyXattrs(source, dest); len(failures) > 0 {
+			return n, &Error{Op: "xattr", Source: source, Dest: dest, Err: &XattrError{failures}}
+		}
+	}
 	return n, nil
 }
 
+// prepareDest checks if dest can be written according to opts. If dest exists
+// and it can be replaced, it's backed up (if opts ask for it) or removed, unless
+// both source and dest are directories (they're merged) or files (dest is
+// truncated when opened). Without backups, directories and files can't replace
+// each other.
+func prepareDest(srcInfo os.FileInfo, source, dest string, opts CopyOptions) error {
+	dstInfo, err := os.Lstat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+	}
+	if !opts.Overwrite {
+		// Like [Exists], a dangling symlink isn't an existing dest, the copy
+		// is created at it's target.
+		if dstInfo.Mode()&os.ModeSymlink != 0 {
+			if exists, err := Exists(dest); err != nil || !exists {
+				return wrapErr("stat", source, dest, err)
+			}
+		}
+		return &Error{Op: "copy", Source: source, Dest: dest, Err: errDestExists}
+	}
+	if os.SameFile(srcInfo, dstInfo) {
+		return &Error{Op: "copy", Source: source, Dest: dest, Err: errSameFile}
+	}
+
+	if opts.Backup.Mode != NoBackup {
+		if srcInfo.IsDir() && dstInfo.IsDir() {
+			return nil
+		}
+		_, err := BackupFile(dest, opts.Backup)
+		return wrapErr("backup", source, dest, err)
+	}
+	// Like cp, a directory and a file never replace each other, so a whole
+	// tree can't be removed by mistake.
+	switch {
+	case dstInfo.IsDir() && !srcInfo.IsDir():
+		return &Error{Op: "copy", Source: source, Dest: dest, Err: fmt.Errorf("cannot overwrite directory with non-directory: %w", os.ErrExist)}
+	case !dstInfo.IsDir() && srcInfo.IsDir():
+		return &Error{Op: "copy", Source: source, Dest: dest, Err: fmt.Errorf("cannot overwrite non-directory with directory: %w", os.ErrExist)}
+	case dstInfo.IsDir() || dstInfo.Mode().IsRegular():
+		return nil
+	}
+	// Symlinks and special files are replaced instead of written through.
+	return wrapErr("remove", source, dest, os.Remove(dest))
+}
+
 // CopyDir copies source directory (and all it's contents) to dest. Currently
 // it doesn't support content merging (if dest exists CopyDir will return an
-// [os.IsExist] error instead of trying to merge it's contents). If source is
+// [os.IsExist] error instead of trying to merge it's contents, use
+// [CopyDirWithOptions] for that). If source is
 // a file, it returns an [os.ErrInvalid] error. Any other errors returned by
-// the functions used inside are also propagated.
+// the functions used inside are also propagated. Copied directories get the
+// permissions of their source, which are applied once their contents are
+// copied so read-only directories can be copied too.
 func CopyDir(source, dest string) error {
+	return CopyDirWithOptions(source, dest, CopyOptions{})
+}
+
+// CopyDirWithOptions does the same as [CopyDir] but, if opts allow it, it
+// merges source into an existing dest replacing the files with the same name
+// (backing them up first if [CopyOptions.Backup] is set). Existing directories
+// keep their permissions, like cp does.
+func CopyDirWithOptions(source, dest string, opts CopyOptions) error {
 	srcStat, err := os.Stat(source)
 	if err != nil {
-		return err
+		return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
 	}
 
 	if !srcStat.IsDir() {
-		return fmt.Errorf("%v is not a directory: %w", source, os.ErrInvalid)
+		return &Error{Op: "copy", Source: source, Dest: dest, Err: fmt.Errorf("source is not a directory: %w", os.ErrInvalid)}
 	}
 
-	_, err = os.Stat(dest)
-	if err == nil {
-		return fmt.Errorf("%v exists and will not be replaced: %w", source, os.ErrExist)
-	} else {
-		if !os.IsNotExist(err) {
-			return err
+	if opts.CheckSpace {
+		usage, err := Usage(source)
+		if err != nil {
+			return wrapErr("stat", source, dest, err)
+		}
+		if err := checkSpace(dest, uint64(usage.Size)); err != nil {
+			return wrapErr("space", source, dest, err)
 		}
+		// The whole tree was checked, there's no need to check each entry.
+		opts.CheckSpace = false
 	}
 
-	entries, err := os.ReadDir(source)
+	err = prepareDest(srcStat, source, dest, opts)
 	if err != nil {
 		return err
 	}
 
+	// The owner needs write access until all contents are copied, the final
+	// permissions are applied at the end.
+	mode, chmod := srcStat.Mode().Perm(), true
+	err = os.Mkdir(dest, mode|0700)
+	if opts.Overwrite && os.IsExist(err) {
+		// An existing directory keeps it's permissions, like cp does, so
+		// it's only changed if the owner can't write to it.
+		dstStat, err := os.Stat(dest)
+		if err != nil {
+			return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+		}
+		mode = dstStat.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		chmod = mode&0700 != 0700
+	} else if err != nil {
+		return &Error{Op: "mkdir", Source: source, Dest: dest, Err: err}
+	}
+	if chmod {
+		err = os.Chmod(dest, mode|0700)
+		if err != nil {
+			return &Error{Op: "chmod", Source: source, Dest: dest, Err: err}
+		}
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return &Error{Op: "readdir", Source: source, Dest: dest, Err: err}
+	}
+
+	// Extended attributes that can't be copied don't stop the copy, they're
+	// reported together at the end.
+	var failures []XattrFailure
 	for _, i := range entries {
 		srcFile := path.Join(source, i.Name())
 		dstFile := path.Join(dest, i.Name())
 
+		var err error
 		if i.IsDir() {
-			err := CopyDir(srcFile, dstFile)
-			if err != nil {
-				return err
-			}
+			err = CopyDirWithOptions(srcFile, dstFile, opts)
 		} else {
-			_, err := CopyFile(srcFile, dstFile)
-			if err != nil {
-				return err
-			}
+			_, err = CopyFileWithOptions(srcFile, dstFile, opts)
+		}
+		var xattrErr *XattrError
+		if errors.As(err, &xattrErr) {
+			failures = append(failures, xattrErr.Failures...)
+		} else if err != nil {
+			return err
 		}
 	}
 
+	if chmod {
+		err = os.Chmod(dest, mode)
+		if err != nil {
+			return &Error{Op: "chmod", Source: source, Dest: dest, Err: err}
+		}
+	}
+	// After the chmod, since it would change the ACL mask.
+	if opts.Xattrs {
+		failures = append(failures, copyXattrs(source, dest)...)
+	}
+	if len(failures) > 0 {
+		return &Error{Op: "xattr", Source: source, Dest: dest, Err: &XattrError{failures}}
+	}
 	return nil
 }
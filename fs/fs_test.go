@@ -0,0 +1,124 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestCopyDirPermissions(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	err := fs.Fixture{
+		"a.txt":          fs.FileEntry("a"),
+		"private/":       fs.DirEntry().WithMode(0700),
+		"private/b.txt":  fs.FileEntry("b"),
+		"readonly/":      fs.DirEntry().WithMode(0555),
+		"readonly/c.txt": fs.FileEntry("c"),
+	}.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CopyDir(src, filepath.Join(root, "dst")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.RemoveAll(root) })
+
+	// Read-only directories are populated before their mode is applied.
+	fs.AssertTree(t, filepath.Join(root, "dst"), fs.Fixture{
+		"a.txt":          fs.FileEntry("a"),
+		"private/":       fs.DirEntry().WithMode(0700),
+		"private/b.txt":  fs.FileEntry("b"),
+		"readonly/":      fs.DirEntry().WithMode(0555),
+		"readonly/c.txt": fs.FileEntry("c"),
+	})
+}
+
+func TestCopyFileDanglingSymlink(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"a.txt":    fs.FileEntry("a"),
+		"dangling": fs.SymlinkEntry("target.txt"),
+		"link":     fs.SymlinkEntry("a.txt"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy is created at the target of the symlink, like os.Create does.
+	if _, err := fs.CopyFile(filepath.Join(root, "a.txt"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.CopyFile(filepath.Join(root, "a.txt"), filepath.Join(root, "link")); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected an exist error copying over a valid symlink, got %v", err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"a.txt":      fs.FileEntry("a"),
+		"dangling":   fs.SymlinkEntry("target.txt"),
+		"link":       fs.SymlinkEntry("a.txt"),
+		"target.txt": fs.FileEntry("a"),
+	})
+}
+
+func TestCopyDirMergeKeepsPermissions(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/":           fs.DirEntry().WithMode(0700),
+		"src/a.txt":      fs.FileEntry("a"),
+		"src/sub/":       fs.DirEntry().WithMode(0700),
+		"src/sub/b.txt":  fs.FileEntry("b"),
+		"dest/":          fs.DirEntry().WithMode(0755),
+		"dest/sub/":      fs.DirEntry().WithMode(0555),
+		"dest/sub/c.txt": fs.FileEntry("c"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.RemoveAll(root) })
+
+	err = fs.CopyDirWithOptions(filepath.Join(root, "src"), filepath.Join(root, "dest"), fs.CopyOptions{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(root, "dest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected dest to keep mode 0755, got %v", info.Mode())
+	}
+	fs.AssertTree(t, filepath.Join(root, "dest"), fs.Fixture{
+		"a.txt":     fs.FileEntry("a"),
+		"sub/":      fs.DirEntry().WithMode(0555),
+		"sub/b.txt": fs.FileEntry("b"),
+		"sub/c.txt": fs.FileEntry("c"),
+	})
+}
+
+func TestCopyOverwriteKindMismatch(t *testing.T) {
+	root := t.TempDir()
+	tree := fs.Fixture{
+		"file":      fs.FileEntry("file"),
+		"dir/":      fs.DirEntry(),
+		"tree/":     fs.DirEntry(),
+		"tree/keep": fs.FileEntry("keep"),
+		"target":    fs.FileEntry("target"),
+	}
+	if err := tree.Create(root); err != nil {
+		t.Fatal(err)
+	}
+	opts := fs.CopyOptions{Overwrite: true}
+
+	_, err := fs.CopyFileWithOptions(filepath.Join(root, "file"), filepath.Join(root, "tree"), opts)
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected an exist error copying a file over a directory, got %v", err)
+	}
+	err = fs.CopyDirWithOptions(filepath.Join(root, "dir"), filepath.Join(root, "target"), opts)
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected an exist error copying a directory over a file, got %v", err)
+	}
+	fs.AssertTree(t, root, tree)
+}
@@ -0,0 +1,28 @@
+package fs
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignored reports wether rel (a path relative to the root being walked) matches
+// any of the patterns. Patterns use the [path.Match] syntax, the ones without
+// a slash are matched against the entry name (so "*.tmp" ignores temporary files
+// at any depth) and the ones with a slash are matched against the whole relative
+// path using forward slashes (so "build/*" only ignores the contents of the build
+// directory at root). Malformed patterns never match.
+func ignored(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	for _, p := range patterns {
+		target := name
+		if strings.Contains(p, "/") {
+			target = rel
+		}
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
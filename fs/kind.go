@@ -0,0 +1,161 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// Kind is the type of entry a path points to, as reported by [Stat] and
+// [Lstat].
+type Kind int
+
+const (
+	KindMissing Kind = iota
+	KindFile
+	KindDir
+	KindSymlink
+	KindDanglingSymlink
+	KindSocket
+	KindFifo
+	KindDevice
+	KindOther
+)
+
+var kindNames = [...]string{
+	KindMissing:         "missing",
+	KindFile:            "file",
+	KindDir:             "directory",
+	KindSymlink:         "symlink",
+	KindDanglingSymlink: "dangling symlink",
+	KindSocket:          "socket",
+	KindFifo:            "fifo",
+	KindDevice:          "device",
+	KindOther:           "other",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+func kindOfMode(mode os.FileMode) Kind {
+	switch {
+	case mode.IsRegular():
+		return KindFile
+	case mode.IsDir():
+		return KindDir
+	case mode&os.ModeSymlink != 0:
+		return KindSymlink
+	case mode&os.ModeSocket != 0:
+		return KindSocket
+	case mode&os.ModeNamedPipe != 0:
+		return KindFifo
+	case mode&os.ModeDevice != 0:
+		return KindDevice
+	default:
+		return KindOther
+	}
+}
+
+// Stat returns the kind of entry path points to following symlinks, so a symlink
+// to a directory is reported as [KindDir]. Symlinks whose target doesn't exist are
+// reported as [KindDanglingSymlink] and paths that don't exist as [KindMissing].
+// Like [Exists], any other error (usually lack of privileges) is propagated.
+func Stat(path string) (Kind, error) {
+	info, err := os.Stat(path)
+	if err == nil {
+		return kindOfMode(info.Mode()), nil
+	}
+	if !os.IsNotExist(err) {
+		return KindMissing, wrapErr("stat", path, "", err)
+	}
+
+	// Either path or the target of a symlink is missing, Lstat tells which one.
+	linfo, lerr := os.Lstat(path)
+	if lerr != nil {
+		if os.IsNotExist(lerr) {
+			return KindMissing, nil
+		}
+		return KindMissing, wrapErr("lstat", path, "", lerr)
+	}
+	if linfo.Mode()&os.ModeSymlink != 0 {
+		return KindDanglingSymlink, nil
+	}
+	return KindMissing, nil
+}
+
+// Lstat does the same as [Stat] but doesn't follow symlinks, any symlink is
+// reported as [KindSymlink] no matter if it's target exists.
+func Lstat(path string) (Kind, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return KindMissing, nil
+		}
+		return KindMissing, wrapErr("lstat", path, "", err)
+	}
+	return kindOfMode(info.Mode()), nil
+}
+
+// Lexists checks wether path exists without following symlinks, so unlike
+// [Exists] it returns true for dangling symlinks.
+func Lexists(path string) (bool, error) {
+	kind, err := Lstat(path)
+	return kind != KindMissing, err
+}
+
+// IsFile checks wether path is a regular file (or a symlink to one).
+func IsFile(path string) (bool, error) {
+	kind, err := Stat(path)
+	return kind == KindFile, err
+}
+
+// IsDir checks wether path is a directory (or a symlink to one).
+func IsDir(path string) (bool, error) {
+	kind, err := Stat(path)
+	return kind == KindDir, err
+}
+
+// IsSymlink checks wether path is a symlink, no matter if it's dangling.
+func IsSymlink(path string) (bool, error) {
+	kind, err := Lstat(path)
+	return kind == KindSymlink, err
+}
+
+// IsExecutable checks wether path is a regular file (or a symlink to one) with
+// any of the execute permission bits set. It doesn't check if the current user
+// is the one allowed to execute it. On Windows, where there are no execute bits,
+// it always returns false.
+func IsExecutable(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, wrapErr("stat", path, "", err)
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0, nil
+}
+
+// IsEmptyDir checks wether path is a directory (or a symlink to one) without
+// any entries. It returns false if path doesn't exist or isn't a directory.
+func IsEmptyDir(path string) (bool, error) {
+	isDir, err := IsDir(path)
+	if err != nil || !isDir {
+		return false, err
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return false, wrapErr("open", path, "", err)
+	}
+	defer dir.Close()
+
+	_, err = dir.Readdirnames(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+	return false, wrapErr("readdir", path, "", err)
+}
@@ -0,0 +1,77 @@
+package fs_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestKinds(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"file":     fs.FileEntry("content"),
+		"run.sh":   fs.FileEntry("#!/bin/sh").WithMode(0755),
+		"empty/":   fs.DirEntry(),
+		"full/a":   fs.FileEntry(""),
+		"to-file":  fs.SymlinkEntry("file"),
+		"to-dir":   fs.SymlinkEntry("empty"),
+		"dangling": fs.SymlinkEntry("nowhere"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		stat, lstat fs.Kind
+	}{
+		{"file", fs.KindFile, fs.KindFile},
+		{"empty", fs.KindDir, fs.KindDir},
+		{"to-file", fs.KindFile, fs.KindSymlink},
+		{"to-dir", fs.KindDir, fs.KindSymlink},
+		{"dangling", fs.KindDanglingSymlink, fs.KindSymlink},
+		{"missing", fs.KindMissing, fs.KindMissing},
+	}
+	for _, c := range cases {
+		p := filepath.Join(root, c.name)
+		if got, err := fs.Stat(p); err != nil || got != c.stat {
+			t.Errorf("Stat(%s): expected %v, got %v (%v)", c.name, c.stat, got, err)
+		}
+		if got, err := fs.Lstat(p); err != nil || got != c.lstat {
+			t.Errorf("Lstat(%s): expected %v, got %v (%v)", c.name, c.lstat, got, err)
+		}
+	}
+
+	check := func(name string, f func(string) (bool, error), path string, want bool) {
+		t.Helper()
+		if got, err := f(filepath.Join(root, path)); err != nil || got != want {
+			t.Errorf("%s(%s): expected %v, got %v (%v)", name, path, want, got, err)
+		}
+	}
+	check("IsFile", fs.IsFile, "to-file", true)
+	check("IsDir", fs.IsDir, "file", false)
+	check("IsSymlink", fs.IsSymlink, "dangling", true)
+	check("Lexists", fs.Lexists, "dangling", true)
+	check("Exists", fs.Exists, "dangling", false)
+	check("IsExecutable", fs.IsExecutable, "run.sh", true)
+	check("IsExecutable", fs.IsExecutable, "file", false)
+	check("IsEmptyDir", fs.IsEmptyDir, "empty", true)
+	check("IsEmptyDir", fs.IsEmptyDir, "full", false)
+	check("IsEmptyDir", fs.IsEmptyDir, "missing", false)
+
+	// A regular file in the middle of a path isn't reported as missing, the
+	// error is propagated like Exists does.
+	if _, err := fs.Stat(filepath.Join(root, "file", "child")); err == nil {
+		t.Error("expected an error when a path component is a file")
+	}
+
+	sock := filepath.Join(root, "sock")
+	if l, err := net.Listen("unix", sock); err == nil {
+		defer l.Close()
+		if got, _ := fs.Stat(sock); got != fs.KindSocket {
+			t.Errorf("expected a socket, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package fs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"strings"
+
+	"github.com/n-mou/yagul/itertools"
+)
+
+// maxRecord is the largest record [RecordsFunc] can return. [Lines] and
+// [Records] don't have this limit.
+const maxRecord = 1 << 30
+
+// recordIterator is a PullIterator2 that opens the file on the first call to
+// Next and closes it on Stop.
+type recordIterator struct {
+	path  string
+	split func(r *bufio.Reader) func() (string, error)
+
+	file    *os.File
+	gz      *gzip.Reader
+	read    func() (string, error)
+	started bool
+	done    bool
+}
+
+func (i *recordIterator) Next() (string, error, bool) {
+	if i.done {
+		return "", nil, false
+	}
+	if !i.started {
+		i.started = true
+		if err := i.open(); err != nil {
+			i.done = true
+			return "", &Error{Op: "open", Source: i.path, Err: err}, true
+		}
+	}
+
+	record, err := i.read()
+	if errors.Is(err, io.EOF) {
+		i.done = true
+		return "", nil, false
+	}
+	if err != nil {
+		i.done = true
+		return "", &Error{Op: "read", Source: i.path, Err: err}, true
+	}
+	return record, nil, true
+}
+
+func (i *recordIterator) Stop() {
+	i.done = true
+	if i.gz != nil {
+		i.gz.Close()
+		i.gz = nil
+	}
+	if i.file != nil {
+		i.file.Close()
+		i.file = nil
+	}
+	i.read = nil
+}
+
+// open opens the file and, if it starts with the gzip magic number,
+// decompresses it on the fly.
+func (i *recordIterator) open() error {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return err
+	}
+	i.file = f
+
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		i.gz, err = gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		r = bufio.NewReader(i.gz)
+	}
+	i.read = i.split(r)
+	return nil
+}
+
+// records returns a re-iterable sequence, every "for range" block gets a new
+// recordIterator and thus opens the file again.
+func records(path string, split func(r *bufio.Reader) func() (string, error)) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		itertools.PullToPush2(&recordIterator{path: path, split: split})(yield)
+	}
+}
+
+// delimited returns a reader of records ending with delim. The last record
+// doesn't need to end with it.
+func delimited(delim byte, trimCR bool) func(r *bufio.Reader) func() (string, error) {
+	return func(r *bufio.Reader) func() (string, error) {
+		return func() (string, error) {
+			record, err := r.ReadString(delim)
+			if err != nil && (!errors.Is(err, io.EOF) || record == "") {
+				return "", err
+			}
+			record = strings.TrimSuffix(record, string(delim))
+			if trimCR {
+				record = strings.TrimSuffix(record, "\r")
+			}
+			return record, nil
+		}
+	}
+}
+
+// Lines returns an iterator over the lines of the file at path without the
+// line endings (both "\n" and "\r\n" are supported). The file isn't opened
+// until the iteration starts and it's closed as soon as it ends or the loop
+// breaks, following the Stop semantics of [itertools.PullIterator2]. Lines can
+// be as long as memory allows and gzipped files are decompressed transparently.
+//
+// If the file can't be opened or read, the error is yielded once and the
+// iteration stops:
+//
+//	for line, err := range fs.Lines("access.log.gz") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(line)
+//	}
+//
+// The returned iterator can be used several times, each time reads the file
+// from the start.
+func Lines(path string) iter.Seq2[string, error] {
+	return records(path, delimited('\n', true))
+}
+
+// Records does the same as [Lines] but splits the file by delim instead of by
+// line endings (eg: 0 for the output of "find -print0"). Records don't have
+// the delimiter and carriage returns aren't removed.
+func Records(path string, delim byte) iter.Seq2[string, error] {
+	return records(path, delimited(delim, false))
+}
+
+// RecordsFunc does the same as [Lines] but splits the file with a
+// [bufio.SplitFunc], like the ones used by [bufio.Scanner] (eg: [bufio.ScanWords]).
+// Records can't be bigger than 1 GB.
+func RecordsFunc(path string, split bufio.SplitFunc) iter.Seq2[string, error] {
+	return records(path, func(r *bufio.Reader) func() (string, error) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxRecord)
+		scanner.Split(split)
+		return func() (string, error) {
+			if scanner.Scan() {
+				return scanner.Text(), nil
+			}
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package fs_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func collect(t *testing.T, seq func(func(string, error) bool)) []string {
+	t.Helper()
+	var got []string
+	for record, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, record)
+	}
+	return got
+}
+
+func TestLines(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("x", 1<<20)
+	plain := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(plain, []byte("one\r\ntwo\n\n"+long+"\nlast"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"one", "two", "", long, "last"}
+	if got := collect(t, fs.Lines(plain)); !slices.Equal(got, want) {
+		t.Errorf("expected %d lines, got %d", len(want), len(got))
+	}
+
+	gzipped := filepath.Join(dir, "compressed.gz")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("a\nb\n"))
+	gz.Close()
+	f.Close()
+	if got := collect(t, fs.Lines(gzipped)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("unexpected gzipped lines: %q", got)
+	}
+
+	// Breaking the loop stops the iteration, ranging again starts over.
+	lines := fs.Lines(plain)
+	for range lines {
+		break
+	}
+	for line := range lines {
+		if line != "one" {
+			t.Errorf("expected the iteration to start over, got %q", line)
+		}
+		break
+	}
+}
+
+func TestLinesMissingFile(t *testing.T) {
+	count := 0
+	for _, err := range fs.Lines(filepath.Join(t.TempDir(), "missing")) {
+		count++
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected a not exist error, got %v", err)
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected the error to be yielded once, got %d iterations", count)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "records")
+	if err := os.WriteFile(p, []byte("a b\x00c\r\n d\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, fs.Records(p, 0)); !slices.Equal(got, []string{"a b", "c\r\n d"}) {
+		t.Errorf("unexpected records: %q", got)
+	}
+	if got := collect(t, fs.RecordsFunc(p, bufio.ScanWords)); !slices.Equal(got, []string{"a", "b\x00c", "d\x00"}) {
+		t.Errorf("unexpected words: %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Mapping is a file mapped into memory, see [Mmap] and [MmapWritable]. It
+// implements [io.Reader] and [io.ReaderAt] and it's contents can be accessed
+// directly with [Mapping.Bytes]. It must be closed with [Mapping.Close] to
+// release the memory. ReadAt is safe for concurrent use but Read isn't, it
+// moves a shared offset.
+type Mapping struct {
+	name   string
+	data   []byte
+	file   *os.File // Only kept open for writable mappings
+	mapped bool     // data comes from mmap instead of being read into memory
+	offset int64
+}
+
+// Mmap maps the file at path into memory for reading. Pages are loaded from
+// disk on demand when they're accessed, which makes random access to big
+// files (eg: indexes) cheap. On Linux it uses mmap, elsewhere the file is
+// read into memory.
+func Mmap(path string) (*Mapping, error) {
+	return mmapPath(path, false)
+}
+
+// MmapWritable maps the file at path into memory for reading and writing.
+// Changes made to the slice returned by [Mapping.Bytes] are written to the
+// file, call [Mapping.Sync] to make sure they reach the disk. The size of the
+// mapping is the size of the file, so it must be resized (eg: with
+// [os.Truncate]) before being mapped to grow it. On platforms without mmap,
+// changes are written on Sync and Close.
+func MmapWritable(path string) (*Mapping, error) {
+	return mmapPath(path, true)
+}
+
+func mmapPath(path string, writable bool) (*Mapping, error) {
+	m, err := mapPath(path, writable)
+	return m, wrapErr("mmap", path, "", err)
+}
+
+func mapPath(path string, writable bool) (*Mapping, error) {
+	flag := os.O_RDONLY
+	if writable {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("source is a directory: %w", os.ErrInvalid)
+	}
+	size := info.Size()
+	if int64(int(size)) != size {
+		f.Close()
+		return nil, fmt.Errorf("file is too big to be mapped: %w", os.ErrInvalid)
+	}
+
+	m := &Mapping{name: path}
+	// Empty files can't be mapped, they get an empty slice.
+	if size > 0 {
+		m.data, m.mapped, err = mmapFile(f, int(size), writable)
+		if err != nil {
+			f.Close()
+			return nil, &os.PathError{Op: "mmap", Path: path, Err: err}
+		}
+	} else {
+		m.data = []byte{}
+	}
+	if writable {
+		m.file = f
+	} else {
+		f.Close()
+	}
+	return m, nil
+}
+
+// Bytes returns the mapped contents. The slice must not be used after Close
+// and, unless the mapping is writable, it must not be modified.
+func (m *Mapping) Bytes() []byte {
+	return m.data
+}
+
+// Len returns the size of the mapping.
+func (m *Mapping) Len() int {
+	return len(m.data)
+}
+
+// ReadAt implements [io.ReaderAt].
+func (m *Mapping) ReadAt(p []byte, off int64) (int, error) {
+	if m.data == nil {
+		return 0, &os.PathError{Op: "read", Path: m.name, Err: os.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: m.name, Err: fmt.Errorf("negative offset %d: %w", off, os.ErrInvalid)}
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read implements [io.Reader], reading from the start of the mapping the
+// first time.
+func (m *Mapping) Read(p []byte) (int, error) {
+	n, err := m.ReadAt(p, m.offset)
+	m.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Sync flushes the changes of a writable mapping to disk. It does nothing for
+// read-only mappings.
+func (m *Mapping) Sync() error {
+	if m.data == nil {
+		return &os.PathError{Op: "sync", Path: m.name, Err: os.ErrClosed}
+	}
+	if m.file == nil || len(m.data) == 0 {
+		return nil
+	}
+	if m.mapped {
+		if err := msync(m.data); err != nil {
+			return &os.PathError{Op: "msync", Path: m.name, Err: err}
+		}
+		return nil
+	}
+	if _, err := m.file.WriteAt(m.data, 0); err != nil {
+		return err
+	}
+	return m.file.Sync()
+}
+
+// Close unmaps the file. Changes to writable mappings are kept but, on Linux,
+// they may not be on disk yet unless Sync was called.
+func (m *Mapping) Close() error {
+	if m.data == nil {
+		return &os.PathError{Op: "close", Path: m.name, Err: os.ErrClosed}
+	}
+	var err error
+	if m.file != nil && !m.mapped {
+		err = m.Sync()
+	}
+	if m.mapped {
+		if unmapErr := munmap(m.data); err == nil && unmapErr != nil {
+			err = &os.PathError{Op: "munmap", Path: m.name, Err: unmapErr}
+		}
+	}
+	if m.file != nil {
+		if closeErr := m.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	m.data, m.file = nil, nil
+	return err
+}
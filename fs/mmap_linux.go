@@ -0,0 +1,38 @@
+//go:build linux
+
+package fs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const msSync = 0x4 // MS_SYNC flag of msync
+
+// mmapFile maps the first size bytes of f, sharing the changes with the file
+// if it's writable.
+func mmapFile(f *os.File, size int, writable bool) ([]byte, bool, error) {
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, prot, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+// msync isn't in the syscall package.
+func msync(data []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), msSync)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
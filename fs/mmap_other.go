@@ -0,0 +1,27 @@
+//go:build !linux
+
+package fs
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// mmapFile reads the whole file into memory since mmap isn't used outside
+// Linux. Changes to writable mappings are written back by [Mapping.Sync].
+func mmapFile(f *os.File, size int, writable bool) ([]byte, bool, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, false, err
+	}
+	return data, false, nil
+}
+
+func munmap(data []byte) error {
+	return errors.ErrUnsupported
+}
+
+func msync(data []byte) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,73 @@
+package fs_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestMmap(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"index": fs.FileEntry("0123456789"),
+		"empty": fs.FileEntry(""),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := fs.Mmap(filepath.Join(root, "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if n, err := m.ReadAt(buf, 3); err != nil || string(buf[:n]) != "3456" {
+		t.Errorf("expected 3456, got %q (%v)", buf[:n], err)
+	}
+	if n, err := m.ReadAt(buf, 8); err != io.EOF || string(buf[:n]) != "89" {
+		t.Errorf("expected 89 and EOF, got %q (%v)", buf[:n], err)
+	}
+	content, err := io.ReadAll(m)
+	if err != nil || string(content) != "0123456789" {
+		t.Errorf("expected the whole file, got %q (%v)", content, err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var pathErr *os.PathError
+	if _, err := m.ReadAt(buf, 0); !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(root, "index") || !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected a closed error with the path, got %v", err)
+	}
+	if err := m.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected a closed error closing twice, got %v", err)
+	}
+
+	m, err = fs.Mmap(filepath.Join(root, "empty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected an empty mapping, got %d bytes", m.Len())
+	}
+	m.Close()
+
+	w, err := fs.MmapWritable(filepath.Join(root, "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(w.Bytes()[2:], "ab")
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"index": fs.FileEntry("01ab456789"),
+		"empty": fs.FileEntry(""),
+	})
+}
@@ -0,0 +1,80 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Move moves source (a file, directory or symlink) to dest. It tries to rename
+// it and, if source and dest live in different file systems, it falls back to
+// copying source (keeping permissions, modification times and symlinks) and
+// removing it afterwards. Named pipes, sockets and devices can't be copied, so
+// trees containing them can only be renamed. If the copy fails, the partial
+// copy is removed and source is left untouched. Like [CopyFile], it returns an
+// [os.ErrExist] error instead of replacing dest.
+func Move(source, dest string) error {
+	return MoveWithOptions(source, dest, CopyOptions{})
+}
+
+// move renames source to dest falling back to a copy between file systems,
+// without checking if dest exists.
+func move(source, dest string) error {
+	err := os.Rename(source, dest)
+	if err == nil || !isCrossDevice(err) {
+		return wrapErr("rename", source, dest, err)
+	}
+
+	if err := copyPreserving(source, dest); err != nil {
+		os.RemoveAll(dest)
+		return wrapErr("copy", source, dest, err)
+	}
+	return wrapErr("remove", source, dest, os.RemoveAll(source))
+}
+
+// copyPreserving copies source into dest keeping it as close to the original
+// as possible: symlinks are copied as symlinks and permissions (including the
+// setuid, setgid and sticky bits) and modification times are preserved.
+func copyPreserving(source, dest string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dest)
+
+	case info.IsDir():
+		if err := os.Mkdir(dest, info.Mode().Perm()|0700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			err := copyPreserving(filepath.Join(source, e.Name()), filepath.Join(dest, e.Name()))
+			if err != nil {
+				return err
+			}
+		}
+
+	case info.Mode().IsRegular():
+		if _, err := CopyFile(source, dest); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("%s is not a file, directory nor symlink: %w", source, os.ErrInvalid)
+	}
+
+	if err := os.Chmod(dest, snapshotPerm(info.Mode())); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,57 @@
+//go:build unix
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// copyPreserving is what Move falls back to between file systems, which can't
+// be set up in a test.
+func TestCopyPreserving(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	err := Fixture{
+		"shared/":     DirEntry(),
+		"shared/a.sh": FileEntry("#!/bin/sh"),
+	}.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modes := map[string]os.FileMode{
+		"shared":      0777 | os.ModeSticky,
+		"shared/a.sh": 0755 | os.ModeSetuid,
+	}
+	for name, mode := range modes {
+		if err := os.Chmod(filepath.Join(src, name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := filepath.Join(dir, "dest")
+	if err := copyPreserving(src, dest); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range modes {
+		info, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() &^ os.ModeType; got != mode {
+			t.Errorf("expected %s to have mode %v, got %v", name, mode, got)
+		}
+	}
+
+	// Named pipes are rejected instead of blocking on them.
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyPreserving(fifo, filepath.Join(dir, "fifo2")); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExpandPath expands and cleans a path written by a person, like the ones in
+// config files (eg: "~/data/$PROJECT/../out"):
+//   - A leading ~ is replaced by the home directory of the current user and
+//     ~name by the one of the user called name.
+//   - Environment variables are replaced like the shell does ($VAR or ${VAR},
+//     unset variables are replaced by an empty string). ${VAR:-default} uses
+//     default if VAR is unset or empty and ${VAR-default} only if it's unset.
+//   - The result is cleaned with [filepath.Clean].
+//
+// Variables are expanded before the ~, so a variable can hold paths like
+// "~/cache".
+func ExpandPath(path string) (string, error) {
+	path = os.Expand(path, expandVar)
+	if !strings.HasPrefix(path, "~") {
+		return filepath.Clean(path), nil
+	}
+
+	// Forward slashes are accepted on every OS (eg: "~/data" on Windows).
+	name, rest, _ := strings.Cut(filepath.ToSlash(path[1:]), "/")
+	var home string
+	if name == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return "", err
+		}
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("expanding %s: %w", path, err)
+		}
+		home = u.HomeDir
+	}
+	return filepath.Join(home, rest), nil
+}
+
+func expandVar(expr string) string {
+	if name, def, ok := strings.Cut(expr, ":-"); ok {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return def
+	}
+	if name, def, ok := strings.Cut(expr, "-"); ok {
+		if value, set := os.LookupEnv(name); set {
+			return value
+		}
+		return def
+	}
+	return os.Getenv(expr)
+}
+
+// RelPath does the same as [filepath.Rel] but base and path can be relative to
+// the working directory, or one relative and the other absolute.
+func RelPath(base, path string) (string, error) {
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(base, path)
+}
+
+// IsInside checks wether path is parent or is inside it. Symlinks are resolved
+// first, one component at a time like the kernel does (so "link/.." is the
+// parent of the target of link, not the directory of link), so a symlink in
+// parent pointing outside of it isn't inside, and neither path needs to exist
+// (the existing part is resolved). This makes it
+// suitable to check that paths taken from untrusted input don't escape a base
+// directory.
+func IsInside(parent, path string) (bool, error) {
+	parent, err := resolvePath(parent)
+	if err != nil {
+		return false, err
+	}
+	path, err = resolvePath(path)
+	if err != nil {
+		return false, err
+	}
+	return isWithin(parent, path), nil
+}
+
+// isWithin checks wether path is parent or is inside it without touching the
+// file system.
+func isWithin(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	if err != nil {
+		// Paths in different volumes.
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute path of path with every symlink of it's
+// existing part resolved. Components are resolved one at a time like the
+// kernel does, so a ".." after a symlink goes to the parent of it's target
+// instead of being cleaned lexically (eg: by [filepath.Abs]).
+func resolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		path = wd + string(filepath.Separator) + path
+	}
+
+	vol := filepath.VolumeName(path)
+	resolved := vol + string(filepath.Separator)
+	pending := splitPath(path[len(vol):])
+	missing := false
+	links := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, name)
+		if missing {
+			resolved = next
+			continue
+		}
+
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			// Nothing below it exists either, the rest is joined as is.
+			missing = true
+			resolved = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > 255 {
+			return "", &os.PathError{Op: "resolve", Path: path, Err: errors.New("too many levels of symbolic links")}
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			targetVol := filepath.VolumeName(target)
+			resolved = targetVol + string(filepath.Separator)
+			target = target[len(targetVol):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
+	return resolved, nil
+}
+
+// splitPath returns the non empty components of path.
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r)) })
+}
+
+// CommonAncestor returns the deepest directory that contains every path (eg:
+// /a/b for /a/b/c and /a/b/d/e). Paths are made absolute and cleaned but
+// symlinks aren't resolved. It returns an [os.ErrInvalid] error if there are no
+// paths or if they have nothing in common (eg: they're in different volumes on
+// Windows).
+func CommonAncestor(paths ...string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no paths given: %w", os.ErrInvalid)
+	}
+
+	var volume string
+	var common []string
+	for i, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+		vol := filepath.VolumeName(abs)
+		parts := strings.Split(strings.Trim(abs[len(vol):], string(filepath.Separator)), string(filepath.Separator))
+		if i == 0 {
+			volume, common = vol, parts
+			continue
+		}
+		if !strings.EqualFold(vol, volume) {
+			return "", fmt.Errorf("%s and %s have no common ancestor: %w", paths[0], p, os.ErrInvalid)
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return volume + string(filepath.Separator) + filepath.Join(common...), nil
+}
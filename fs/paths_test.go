@@ -0,0 +1,99 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Setenv("PROJECT", "yagul")
+	t.Setenv("EMPTY", "")
+	os.Unsetenv("UNSET")
+
+	for path, want := range map[string]string{
+		"~/data/$PROJECT/../out":    filepath.Join(home, "data", "out"),
+		"~":                         home,
+		"/srv/${PROJECT}/cache":     "/srv/yagul/cache",
+		"/srv/${UNSET:-default}":    "/srv/default",
+		"/srv/${EMPTY:-default}":    "/srv/default",
+		"/srv/${EMPTY-default}/x":   "/srv/x",
+		"/srv/${UNSET-default}":     "/srv/default",
+		"/srv/$UNSET/./x//y/":       "/srv/x/y",
+		"relative/../path/$PROJECT": filepath.Join("path", "yagul"),
+	} {
+		got, err := fs.ExpandPath(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if got != filepath.FromSlash(want) {
+			t.Errorf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"base/sub/file":   fs.FileEntry(""),
+		"base/sub/esc":    fs.SymlinkEntry("../../outside/inner"),
+		"base/escape":     fs.SymlinkEntry(".."),
+		"base/inner-link": fs.SymlinkEntry("sub"),
+		"outside/file":    fs.FileEntry(""),
+		"outside/inner/":  fs.DirEntry(),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(root, "base")
+
+	for path, want := range map[string]bool{
+		"base":                    true,
+		"base/sub/file":           true,
+		"base/sub/missing/file":   true,
+		"base/inner-link/file":    true,
+		"base/escape/outside":     false,
+		"base/../outside/file":    false,
+		"base/escape/base/sub":    true,
+		"basement":                false,
+		"outside/missing/../file": false,
+	} {
+		got, err := fs.IsInside(base, filepath.Join(root, path))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if got != want {
+			t.Errorf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+	// The kernel resolves esc before applying "..", so this is outside/x
+	// even if it's lexically base/sub/x. filepath.Join would clean it.
+	escape := base + "/sub/esc/../x"
+	if got, err := fs.IsInside(base, escape); err != nil || got {
+		t.Errorf("%s: expected false, got %v (%v)", escape, got, err)
+	}
+}
+
+func TestCommonAncestor(t *testing.T) {
+	root := t.TempDir()
+	got, err := fs.CommonAncestor(filepath.Join(root, "a/b/c"), filepath.Join(root, "a/b/d/e"), filepath.Join(root, "a/bb"))
+	if err != nil || got != filepath.Join(root, "a") {
+		t.Errorf("expected %s, got %s (%v)", filepath.Join(root, "a"), got, err)
+	}
+	got, err = fs.CommonAncestor(filepath.Join(root, "a/b"))
+	if err != nil || got != filepath.Join(root, "a/b") {
+		t.Errorf("expected %s, got %s (%v)", filepath.Join(root, "a/b"), got, err)
+	}
+	if _, err := fs.CommonAncestor(); err == nil {
+		t.Error("expected an error without paths")
+	}
+
+	rel, err := fs.RelPath(root, filepath.Join(root, "a/b"))
+	if err != nil || rel != filepath.Join("a", "b") {
+		t.Errorf("expected a/b, got %s (%v)", rel, err)
+	}
+}
@@ -0,0 +1,124 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// SpaceError is returned (wrapped in an [*Error]) by copies with
+// [CopyOptions.CheckSpace] when the destination file system doesn't have
+// enough free space. Nothing is copied.
+type SpaceError struct {
+	Path      string // Destination path
+	Needed    uint64
+	Available uint64
+}
+
+func (e *SpaceError) Error() string {
+	return fmt.Sprintf("not enough space to copy to %s: %d bytes needed, %d available", e.Path, e.Needed, e.Available)
+}
+
+// QuotaError is returned (wrapped in an [*Error]) by copies with a [Quota]
+// when it runs out.
+type QuotaError struct {
+	Path  string // Path being copied when the quota ran out
+	Limit string // "bytes" or "files"
+	Max   int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota of %d %s exceeded copying %s", e.Max, e.Limit, e.Path)
+}
+
+// Quota limits the bytes and files written by the copies that use it (see
+// [CopyOptions.Quota]), once it runs out they fail with a [*QuotaError]. It
+// can be shared by several copies to limit their total and it's safe for
+// concurrent use. Copies that are rejected, or undone because the quota ran
+// out, are given back.
+type Quota struct {
+	maxBytes int64
+	maxFiles int64
+
+	mu    sync.Mutex
+	bytes int64
+	files int64
+}
+
+// NewQuota returns a Quota that allows writing up to maxBytes bytes and
+// maxFiles files. A limit of 0 (or less) disables it.
+func NewQuota(maxBytes int64, maxFiles int) *Quota {
+	return &Quota{maxBytes: maxBytes, maxFiles: int64(maxFiles)}
+}
+
+// Used returns the bytes and files taken from the quota so far.
+func (q *Quota) Used() (int64, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.bytes, int(q.files)
+}
+
+func (q *Quota) addFile(path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxFiles > 0 && q.files+1 > q.maxFiles {
+		return &QuotaError{path, "files", q.maxFiles}
+	}
+	q.files++
+	return nil
+}
+
+func (q *Quota) addBytes(path string, n int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.maxBytes > 0 && q.bytes+n > q.maxBytes {
+		return &QuotaError{path, "bytes", q.maxBytes}
+	}
+	q.bytes += n
+	return nil
+}
+
+// release gives back files and bytes taken by a copy that was undone.
+func (q *Quota) release(files, bytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.files -= files
+	q.bytes -= bytes
+}
+
+// quotaWriter takes every write to path from the quota before doing it.
+type quotaWriter struct {
+	w    io.Writer
+	q    *Quota
+	path string
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if err := w.q.addBytes(w.path, int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(p)
+	if n < len(p) {
+		w.q.release(0, int64(len(p)-n))
+	}
+	return n, err
+}
+
+// checkSpace returns a [*SpaceError] if the file system of dest (which may not
+// exist yet) has less than needed bytes available. Platforms where the free
+// space is unknown aren't checked.
+func checkSpace(dest string, needed uint64) error {
+	available, err := FreeSpace(filepath.Dir(dest))
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if needed > available {
+		return &SpaceError{dest, needed, available}
+	}
+	return nil
+}
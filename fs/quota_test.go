@@ -0,0 +1,99 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestQuota(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/a.txt":     fs.FileEntry("aaaa"),
+		"src/b.txt":     fs.FileEntry("bbbb"),
+		"src/sub/c.txt": fs.FileEntry("cccc"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "src")
+
+	quota := fs.NewQuota(0, 2)
+	err = fs.CopyDirWithOptions(src, filepath.Join(root, "files"), fs.CopyOptions{Quota: quota})
+	var quotaErr *fs.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != "files" {
+		t.Errorf("expected a files quota error, got %v", err)
+	}
+	var e *fs.Error
+	if !errors.As(err, &e) || e.Op != "quota" {
+		t.Errorf("expected the quota error in an *fs.Error, got %#v", err)
+	}
+	if bytes, files := quota.Used(); bytes != 8 || files != 2 {
+		t.Errorf("expected 8 bytes and 2 files used, got %d and %d", bytes, files)
+	}
+
+	quota = fs.NewQuota(6, 0)
+	err = fs.CopyDirWithOptions(src, filepath.Join(root, "bytes"), fs.CopyOptions{Quota: quota})
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != "bytes" {
+		t.Errorf("expected a bytes quota error, got %v", err)
+	}
+	// The file that didn't fit isn't left truncated.
+	fs.AssertTree(t, filepath.Join(root, "bytes"), fs.Fixture{"a.txt": fs.FileEntry("aaaa")})
+	if bytes, files := quota.Used(); bytes != 4 || files != 1 {
+		t.Errorf("expected 4 bytes and 1 file used, got %d and %d", bytes, files)
+	}
+
+	// Copies rejected because dest exists don't use the quota.
+	quota = fs.NewQuota(12, 3)
+	_, err = fs.CopyFileWithOptions(filepath.Join(src, "a.txt"), filepath.Join(root, "bytes/a.txt"), fs.CopyOptions{Quota: quota})
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected an exist error, got %v", err)
+	}
+	if bytes, files := quota.Used(); bytes != 0 || files != 0 {
+		t.Errorf("expected no quota used, got %d bytes and %d files", bytes, files)
+	}
+
+	err = fs.CopyDirWithOptions(src, filepath.Join(root, "ok"), fs.CopyOptions{Quota: fs.NewQuota(12, 3), CheckSpace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	root := t.TempDir()
+	free, err := fs.FreeSpace(root)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A sparse file bigger than the free space.
+	huge := filepath.Join(root, "src/huge")
+	if err := os.Mkdir(filepath.Dir(huge), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(huge, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(huge, int64(free)+1<<30); err != nil {
+		t.Skipf("can't create a sparse file: %v", err)
+	}
+
+	_, err = fs.CopyFileWithOptions(huge, filepath.Join(root, "copy"), fs.CopyOptions{CheckSpace: true})
+	var spaceErr *fs.SpaceError
+	if !errors.As(err, &spaceErr) {
+		t.Errorf("expected a space error, got %v", err)
+	}
+	err = fs.CopyDirWithOptions(filepath.Dir(huge), filepath.Join(root, "dir-copy"), fs.CopyOptions{CheckSpace: true})
+	if !errors.As(err, &spaceErr) {
+		t.Errorf("expected a space error copying the directory, got %v", err)
+	}
+	if exists, _ := fs.Lexists(filepath.Join(root, "dir-copy")); exists {
+		t.Error("the copy started without enough space")
+	}
+}
@@ -0,0 +1,178 @@
+package fs
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrProtected is returned when removing a path that is protected, like the
+// root of the file system or the home directory.
+var ErrProtected = errors.New("refusing to remove protected path")
+
+// RemoveOptions tunes [RemoveAllWithOptions]. The zero value behaves like
+// [RemoveAll].
+type RemoveOptions struct {
+	// Base makes paths outside of it protected (checked with [IsInside], so
+	// symlinks can't be used to escape it).
+	Base string
+	// DryRun doesn't remove anything, it only returns what would be removed.
+	DryRun bool
+	// ContinueOnError keeps removing the rest of entries when an entry can't
+	// be removed and returns all the failures joined at the end. Otherwise it
+	// stops at the first failure.
+	ContinueOnError bool
+	// Overwrite replaces the content of files with random data before
+	// removing them, so it can't be recovered from the disk. Keep in mind it's
+	// not effective in copy on write file systems (eg: btrfs, ZFS) nor in
+	// SSDs that remap blocks, and that other hard links to a file see the
+	// random data too.
+	Overwrite bool
+}
+
+// RemoveAll does the same as [os.RemoveAll] but it refuses to remove the root
+// of the file system, the home directory or any of it's parents, returning an
+// [ErrProtected] error, and it removes the contents of read-only directories
+// by making them writable first.
+func RemoveAll(path string) error {
+	_, err := RemoveAllWithOptions(path, RemoveOptions{})
+	return err
+}
+
+// RemoveAllWithOptions does the same as [RemoveAll] with the safeguards and
+// modes of opts. It returns the paths removed (or that would be removed, with
+// [RemoveOptions.DryRun]) with the contents of each directory before the
+// directory.
+func RemoveAllWithOptions(path string, opts RemoveOptions) ([]string, error) {
+	if err := checkRemovable(path, opts.Base); err != nil {
+		return nil, &Error{Op: "remove", Source: path, Err: err}
+	}
+	r := remover{opts: opts}
+	r.remove(path)
+	return r.removed, errors.Join(r.errs...)
+}
+
+// checkRemovable returns an [ErrProtected] error if path is protected. The
+// last element of path isn't resolved, so a symlink can be removed even if it
+// points to a protected path.
+func checkRemovable(path, base string) error {
+	// The path isn't cleaned before resolving it, since "link/.." isn't the
+	// directory of link.
+	vol := filepath.VolumeName(path)
+	rest := strings.TrimRightFunc(path[len(vol):], func(r rune) bool { return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r)) })
+	i := strings.LastIndexFunc(rest, func(r rune) bool { return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r)) })
+	dir, name := vol+rest[:i+1], rest[i+1:]
+	if dir == vol {
+		dir += "."
+	}
+
+	var target string
+	var err error
+	if name == "" || name == "." || name == ".." {
+		target, err = resolvePath(path)
+	} else {
+		target, err = resolvePath(dir)
+		target = filepath.Join(target, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if filepath.Dir(target) == target {
+		return fmt.Errorf("%s is the root of the file system: %w", path, ErrProtected)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if home, err = resolvePath(home); err == nil && isWithin(target, home) {
+			return fmt.Errorf("%s contains the home directory: %w", path, ErrProtected)
+		}
+	}
+	if base != "" {
+		base, err = resolvePath(base)
+		if err != nil {
+			return err
+		}
+		if !isWithin(base, target) {
+			return fmt.Errorf("%s is outside %s: %w", path, base, ErrProtected)
+		}
+	}
+	return nil
+}
+
+type remover struct {
+	opts    RemoveOptions
+	removed []string
+	errs    []error
+}
+
+// remove removes path and it's contents and reports wether it succeeded.
+func (r *remover) remove(path string) bool {
+	if len(r.errs) > 0 && !r.opts.ContinueOnError {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true
+		}
+		return r.fail("lstat", path, err)
+	}
+
+	if info.IsDir() {
+		// Entries can't be removed from directories without write permission.
+		if !r.opts.DryRun && info.Mode().Perm()&0700 != 0700 {
+			if err := os.Chmod(path, info.Mode().Perm()|0700); err != nil {
+				return r.fail("chmod", path, err)
+			}
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return r.fail("readdir", path, err)
+		}
+		ok := true
+		for _, e := range entries {
+			ok = r.remove(filepath.Join(path, e.Name())) && ok
+		}
+		if !ok {
+			return false
+		}
+	} else if r.opts.Overwrite && info.Mode().IsRegular() && !r.opts.DryRun {
+		if err := overwriteFile(path, info.Size()); err != nil {
+			return r.fail("overwrite", path, err)
+		}
+	}
+
+	if !r.opts.DryRun {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return r.fail("remove", path, err)
+		}
+	}
+	r.removed = append(r.removed, path)
+	return true
+}
+
+func (r *remover) fail(op, path string, err error) bool {
+	r.errs = append(r.errs, &Error{Op: op, Source: path, Err: err})
+	return false
+}
+
+// overwriteFile replaces the content of the file at path with size random
+// bytes and flushes it to disk.
+func overwriteFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(f, rand.Reader, size)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
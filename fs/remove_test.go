@@ -0,0 +1,94 @@
+package fs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestRemoveAll(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(root, "home"))
+	err := fs.Fixture{
+		"home/":               fs.DirEntry(),
+		"base/data/a.txt":     fs.FileEntry("a"),
+		"base/data/ro/b.txt":  fs.FileEntry("b"),
+		"base/data/ro/":       fs.DirEntry().WithMode(0555),
+		"base/escape":         fs.SymlinkEntry("../outside"),
+		"outside/keep.txt":    fs.FileEntry("keep"),
+		"base/secret/key.pem": fs.FileEntry("secret"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(root, "base")
+
+	// Protected paths, always in dry run mode in case the checks are broken.
+	for _, path := range []string{string(filepath.Separator), filepath.Join(root, "home"), root} {
+		if _, err := fs.RemoveAllWithOptions(path, fs.RemoveOptions{DryRun: true}); !errors.Is(err, fs.ErrProtected) {
+			t.Errorf("%s: expected a protected error, got %v", path, err)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "outside"), filepath.Join(base, "escape/keep.txt")} {
+		if _, err := fs.RemoveAllWithOptions(path, fs.RemoveOptions{Base: base, DryRun: true}); !errors.Is(err, fs.ErrProtected) {
+			t.Errorf("%s: expected a protected error outside the base, got %v", path, err)
+		}
+	}
+
+	removed, err := fs.RemoveAllWithOptions(filepath.Join(base, "data"), fs.RemoveOptions{Base: base, DryRun: true})
+	want := []string{"data/a.txt", "data/ro/b.txt", "data/ro", "data"}
+	for i := range want {
+		want[i] = filepath.Join(base, want[i])
+	}
+	if err != nil || !slices.Equal(removed, want) {
+		t.Errorf("expected %v, got %v (%v)", want, removed, err)
+	}
+
+	if err := fs.RemoveAll(filepath.Join(base, "data")); err != nil {
+		t.Fatal(err)
+	}
+	// The symlink is removed, not what it points to.
+	if err := fs.RemoveAll(filepath.Join(base, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.RemoveAllWithOptions(filepath.Join(base, "secret"), fs.RemoveOptions{Base: base, Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"home/":            fs.DirEntry(),
+		"base/":            fs.DirEntry(),
+		"outside/keep.txt": fs.FileEntry("keep"),
+	})
+	if err := fs.RemoveAll(filepath.Join(root, "missing")); err != nil {
+		t.Errorf("expected no error removing a missing path, got %v", err)
+	}
+}
+
+func TestRemoveAllBaseEscape(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"base/sub/esc":       fs.SymlinkEntry("../../outside/inner"),
+		"outside/inner/":     fs.DirEntry(),
+		"outside/victim.txt": fs.FileEntry("keep"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(root, "base")
+
+	// The kernel resolves esc before applying "..", so this is
+	// outside/victim.txt. filepath.Join would clean it.
+	path := base + "/sub/esc/../victim.txt"
+	if _, err := fs.RemoveAllWithOptions(path, fs.RemoveOptions{Base: base}); !errors.Is(err, fs.ErrProtected) {
+		t.Errorf("expected a protected error, got %v", err)
+	}
+	fs.AssertTree(t, filepath.Join(root, "outside"), fs.Fixture{
+		"inner/":     fs.DirEntry(),
+		"victim.txt": fs.FileEntry("keep"),
+	})
+}
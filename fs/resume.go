@@ -0,0 +1,192 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// resumeChunk is how many bytes are copied between checkpoints. A failure only
+// loses the bytes copied since the last one.
+const resumeChunk = 16 << 20
+
+// resumeState is the record saved next to the partial file after each
+// checkpoint.
+type resumeState struct {
+	Source  string    `json:"source"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Offset  int64     `json:"offset"`
+	SHA256  string    `json:"sha256"` // Hash of the first Offset bytes
+}
+
+// CopyFileResumable copies source to dest like [CopyFile] but if it fails
+// halfway (eg: the source lives in a flaky network mount) calling it again
+// resumes the copy instead of starting from zero.
+//
+// The data is written to dest+".partial" and, every 16 MB, the partial file is
+// flushed to disk and a small state record is saved in dest+".partial.state"
+// with the copied offset and the SHA-256 of the copied prefix. When resuming,
+// the prefix of the partial file is hashed again and if it matches the record
+// (and the source has the same size and modification time) the copy continues
+// from that offset, otherwise it starts over. Once finished, the partial file is
+// renamed to dest and the state record is removed.
+//
+// It returns the number of bytes copied by this call, which is less than the
+// size of the file when a copy is resumed. Like [CopyFile], it returns an
+// [os.ErrInvalid] error if source is a directory and an [os.ErrExist] error if
+// dest exists.
+func CopyFileResumable(source, dest string) (int64, error) {
+	n, err := copyResumable(source, dest, nil)
+	return n, wrapErr("copy", source, dest, err)
+}
+
+// copyResumable is CopyFileResumable with an optional wrapper of the source
+// reader.
+func copyResumable(source, dest string, wrap func(io.Reader) io.Reader) (int64, error) {
+	srcInfo, err := os.Stat(source)
+	if err != nil {
+		return 0, &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+	}
+	if srcInfo.IsDir() {
+		return 0, &Error{Op: "copy", Source: source, Dest: dest, Err: fmt.Errorf("source is a directory: %w", os.ErrInvalid)}
+	}
+	dstExists, err := Exists(dest)
+	if err != nil {
+		return 0, &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+	}
+	if dstExists {
+		return 0, &Error{Op: "copy", Source: source, Dest: dest, Err: errDestExists}
+	}
+
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return 0, err
+	}
+	partialPath := dest + ".partial"
+	statePath := partialPath + ".state"
+
+	partial, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return 0, &Error{Op: "create", Source: source, Dest: partialPath, Err: err}
+	}
+	defer partial.Close()
+
+	state := resumeState{Source: absSource, Size: srcInfo.Size(), ModTime: srcInfo.ModTime()}
+	hasher := sha256.New()
+	if prev, ok := loadResumeState(statePath); ok && prev.matches(state) {
+		ok, err := verifyPrefix(partial, prev.Offset, prev.SHA256, hasher)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			state = prev
+		} else {
+			hasher.Reset()
+		}
+	}
+	if err := partial.Truncate(state.Offset); err != nil {
+		return 0, err
+	}
+	if _, err := partial.Seek(state.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return 0, &Error{Op: "open", Source: source, Dest: dest, Err: err}
+	}
+	defer srcFile.Close()
+	if _, err := srcFile.Seek(state.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var src io.Reader = srcFile
+	if wrap != nil {
+		src = wrap(src)
+	}
+
+	var copied int64
+	for {
+		n, err := io.CopyN(io.MultiWriter(partial, hasher), src, resumeChunk)
+		copied += n
+		if n > 0 {
+			if syncErr := partial.Sync(); syncErr != nil {
+				return copied, syncErr
+			}
+			state.Offset += n
+			state.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+			if saveErr := saveResumeState(statePath, state); saveErr != nil {
+				return copied, saveErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return copied, err
+		}
+	}
+
+	if err := partial.Close(); err != nil {
+		return copied, err
+	}
+	if err := os.Rename(partialPath, dest); err != nil {
+		return copied, err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return copied, err
+	}
+	return copied, nil
+}
+
+// matches checks that the record was saved while copying the same version of
+// the same source.
+func (s resumeState) matches(current resumeState) bool {
+	return s.Source == current.Source &&
+		s.Size == current.Size &&
+		s.ModTime.Equal(current.ModTime) &&
+		s.Offset <= s.Size
+}
+
+func loadResumeState(path string) (resumeState, bool) {
+	var state resumeState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false
+	}
+	return state, true
+}
+
+func saveResumeState(path string, state resumeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0666)
+}
+
+// verifyPrefix hashes the first n bytes of f into h and compares the result
+// with want. It returns false if f is shorter than n.
+func verifyPrefix(f *os.File, n int64, want string, h hash.Hash) (bool, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	copied, err := io.CopyN(h, f, n)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return copied == n && hex.EncodeToString(h.Sum(nil)) == want, nil
+}
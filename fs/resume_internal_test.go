@@ -0,0 +1,108 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// flakyReader fails once n bytes have been read, like a network mount that
+// goes away in the middle of a copy.
+type flakyReader struct {
+	r io.Reader
+	n int64
+}
+
+var errFlaky = errors.New("mount went away")
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, errFlaky
+	}
+	if int64(len(p)) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= int64(n)
+	return n, err
+}
+
+func TestCopyFileResumable(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	content := make([]byte, resumeChunk+1000)
+	rand.New(rand.NewSource(1)).Read(content)
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	failAfter := func(n int64) func(io.Reader) io.Reader {
+		return func(r io.Reader) io.Reader { return &flakyReader{r, n} }
+	}
+	copied, err := copyResumable(src, dst, failAfter(resumeChunk+10))
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("expected the flaky error, got %v", err)
+	}
+	if copied != resumeChunk+10 {
+		t.Errorf("expected %d bytes copied, got %d", resumeChunk+10, copied)
+	}
+
+	// The bytes read before the failure are checkpointed too, so only the
+	// remaining ones are copied.
+	copied, err = CopyFileResumable(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 990 {
+		t.Errorf("expected the last 990 bytes to be copied, got %d", copied)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("copied content differs from the source")
+	}
+	for _, leftover := range []string{dst + ".partial", dst + ".partial.state"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", leftover)
+		}
+	}
+}
+
+func TestCopyFileResumableCorruptedPartial(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	content := bytes.Repeat([]byte("yagul"), resumeChunk/5+100)
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copyResumable(src, dst, func(r io.Reader) io.Reader { return &flakyReader{r, resumeChunk} }); err == nil {
+		t.Fatal("expected the copy to fail")
+	}
+
+	f, err := os.OpenFile(dst+".partial", os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("X"), 42)
+	f.Close()
+
+	copied, err := CopyFileResumable(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != int64(len(content)) {
+		t.Errorf("expected the copy to start over, only %d bytes were copied", copied)
+	}
+	got, _ := os.ReadFile(dst)
+	if !bytes.Equal(got, content) {
+		t.Error("copied content differs from the source")
+	}
+}
@@ -0,0 +1,295 @@
+package fs
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBackupTimeFormat is the layout used to name rotated files if
+// [RotateOptions.TimeFormat] is empty.
+const DefaultBackupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions tunes a [RotatingWriter]. The zero value never rotates, so at
+// least MaxSize or Interval should be set.
+type RotateOptions struct {
+	// MaxSize rotates the file before a write would make it bigger than
+	// MaxSize bytes. A single write bigger than MaxSize goes to a file of it's
+	// own.
+	MaxSize int64
+	// Interval rotates the file before a write if it was opened more than
+	// Interval ago.
+	Interval time.Duration
+	// Backups is the number of rotated files kept, the oldest ones are
+	// removed. If it's 0, every rotated file is kept.
+	Backups int
+	// TimeFormat is the [time.Time.Format] layout of the timestamp appended
+	// to the name of rotated files (eg: app.log.2024-01-02T15-04-05.000). It
+	// defaults to [DefaultBackupTimeFormat] and it must be parseable back by
+	// [time.Parse] so old backups can be found.
+	TimeFormat string
+	// Compress gzips rotated files in the background, adding ".gz" to their
+	// name.
+	Compress bool
+	// Perm is the permissions of new files, 0644 by default.
+	Perm os.FileMode
+}
+
+// RotatingWriter is an [io.WriteCloser] that writes to a file and rotates it
+// when it gets too big or too old, like log rotation tools do: the current file
+// is atomically renamed with a timestamp suffix and a new one is created with
+// the original name. It's safe for concurrent use, so a single RotatingWriter
+// can be shared by several loggers.
+type RotatingWriter struct {
+	name string
+	opts RotateOptions
+
+	mu     sync.Mutex
+	file   *os.File // nil if reopening it after a rotation failed
+	closed bool
+	size   int64
+	opened time.Time
+
+	jobs    sync.Mutex // Serializes the background compress and prune jobs
+	wg      sync.WaitGroup
+	bgMu    sync.Mutex
+	bgError error
+}
+
+// NewRotatingWriter opens (or creates) the file at name in append mode and
+// returns a writer that rotates it according to opts.
+func NewRotatingWriter(name string, opts RotateOptions) (*RotatingWriter, error) {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = DefaultBackupTimeFormat
+	}
+	if opts.Perm == 0 {
+		opts.Perm = 0644
+	}
+	w := &RotatingWriter{name: name, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, &Error{Op: "open", Source: name, Err: err}
+	}
+	return w, nil
+}
+
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.opts.Perm)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write writes p to the current file, rotating it first if needed.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, &Error{Op: "write", Source: w.name, Err: os.ErrClosed}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, &Error{Op: "open", Source: w.name, Err: err}
+		}
+	}
+
+	tooBig := w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize
+	tooOld := w.opts.Interval > 0 && time.Since(w.opened) >= w.opts.Interval
+	if tooBig || tooOld {
+		if err := w.rotate(); err != nil {
+			return 0, &Error{Op: "rotate", Source: w.name, Err: err}
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, wrapErr("write", w.name, "", err)
+}
+
+// Rotate rotates the file right away, no matter it's size or age.
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return &Error{Op: "rotate", Source: w.name, Err: os.ErrClosed}
+	}
+	return wrapErr("rotate", w.name, "", w.rotate())
+}
+
+// rotate must be called with w.mu held.
+func (w *RotatingWriter) rotate() error {
+	backup, err := w.backupName(time.Now())
+	if err != nil {
+		return err
+	}
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	if err == nil {
+		err = os.Rename(w.name, backup)
+	}
+	if err != nil {
+		// Keep writing to the same file instead of leaving w closed. If it
+		// can't be reopened either, the next write tries again.
+		return errors.Join(err, w.open())
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if !w.opts.Compress {
+		return w.prune()
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.jobs.Lock()
+		defer w.jobs.Unlock()
+		err := gzipFile(backup)
+		if os.IsNotExist(err) {
+			// A previous job pruned it before it's turn to be compressed.
+			err = nil
+		}
+		if err == nil {
+			err = w.prune()
+		}
+		if err != nil {
+			w.bgMu.Lock()
+			w.bgError = errors.Join(w.bgError, err)
+			w.bgMu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// backupName returns a free name for a backup rotated at t.
+func (w *RotatingWriter) backupName(t time.Time) (string, error) {
+	for {
+		name := w.name + "." + t.Format(w.opts.TimeFormat)
+		taken, err := Lexists(name)
+		if err == nil && !taken {
+			taken, err = Lexists(name + ".gz")
+		}
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return name, nil
+		}
+		// Rotated twice within the precision of the format.
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// Backups returns the paths of the rotated files from newest to oldest.
+func (w *RotatingWriter) Backups() ([]string, error) {
+	dir := filepath.Dir(w.name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, &Error{Op: "readdir", Source: w.name, Err: err}
+	}
+
+	type backup struct {
+		path string
+		t    time.Time
+	}
+	var backups []backup
+	prefix := filepath.Base(w.name) + "."
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(w.opts.TimeFormat, strings.TrimSuffix(stamp, ".gz"))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(dir, e.Name()), t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+// prune removes the oldest backups beyond opts.Backups.
+func (w *RotatingWriter) prune() error {
+	if w.opts.Backups <= 0 {
+		return nil
+	}
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(w.opts.Backups, len(backups)):] {
+		if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the current file and waits for the background compressions to
+// finish. It returns any error those compressions had.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.closed {
+		err = os.ErrClosed
+	} else if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	w.bgMu.Lock()
+	defer w.bgMu.Unlock()
+	return wrapErr("close", w.name, "", errors.Join(err, w.bgError))
+}
+
+// gzipFile compresses path into path+".gz" atomically and removes path.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, src)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	if _, err := CopyReaderAtomic(path+".gz", pr, info.Mode().Perm()); err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,146 @@
+package fs_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w, err := fs.NewRotatingWriter(name, fs.RotateOptions{MaxSize: 100, Backups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 goroutines writing 10 lines of 10 bytes each fill 4 files.
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, err := w.Write([]byte(strings.Repeat(string(rune('a'+i)), 9) + "\n")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("closed")); err == nil {
+		t.Error("expected writing to a closed writer to fail")
+	}
+
+	current, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current) != 100 {
+		t.Errorf("expected the current file to be full, it has %d bytes", len(current))
+	}
+
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".gz") {
+			t.Errorf("%s was not compressed", b)
+			continue
+		}
+		f, err := os.Open(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(gz)
+		f.Close()
+		if err != nil || len(content) != 100 {
+			t.Errorf("%s: expected 100 bytes, got %d (%v)", b, len(content), err)
+		}
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w, err := fs.NewRotatingWriter(name, fs.RotateOptions{Interval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	time.Sleep(30 * time.Millisecond)
+	w.Write([]byte("second\n"))
+
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected a backup, got %v", backups)
+	}
+	if content, _ := os.ReadFile(backups[0]); string(content) != "first\n" {
+		t.Errorf("unexpected backup content %q", content)
+	}
+	if content, _ := os.ReadFile(name); string(content) != "second\n" {
+		t.Errorf("unexpected current content %q", content)
+	}
+}
+
+func TestRotatingWriterRecovers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files can't be removed")
+	}
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "app.log")
+	w, err := fs.NewRotatingWriter(name, fs.RotateOptions{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("123456789\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither renaming nor reopening the file works without it's directory.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("expected the rotation to fail")
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("recovered\n")); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "recovered\n" {
+		t.Errorf("expected the file to be reopened, it has %q", content)
+	}
+}
@@ -0,0 +1,434 @@
+package fs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotIndexName = "index.json"
+
+// SnapshotOptions tunes [Snapshot].
+type SnapshotOptions struct {
+	// Store keeps file contents in a [CAS] instead of inside the snapshot
+	// file, so identical files are stored once across every snapshot.
+	Store *CAS
+	// Previous is the path of an earlier snapshot of the same tree. Files whose
+	// size and modification time haven't changed since then aren't read nor
+	// stored again, [Restore] takes them from Previous (or the snapshots it was
+	// based on), so all of them must be kept.
+	Previous string
+	// Ignore has patterns of entries left out of the snapshot, with the syntax
+	// of [TreeOptions.Ignore].
+	Ignore []string
+}
+
+// SnapshotIndex describes the tree saved in a snapshot.
+type SnapshotIndex struct {
+	Created time.Time `json:"created"`
+	// Previous and Store are relative to the directory of the snapshot.
+	Previous string          `json:"previous,omitempty"`
+	Store    string          `json:"store,omitempty"`
+	Entries  []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is an entry of a snapshot. Path uses forward slashes and is
+// relative to the root of the tree, which is the first entry with path ".".
+// Only files have a digest (the hex encoded SHA-256 of their content) and only
+// symlinks have a target.
+type SnapshotEntry struct {
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Size    int64       `json:"size,omitempty"`
+	Digest  string      `json:"digest,omitempty"`
+	Target  string      `json:"target,omitempty"`
+}
+
+// Snapshot saves the structure and contents of the tree at root to the file at
+// snapshot (which must not exist) so [Restore] can recreate it exactly,
+// including permissions (setuid, setgid and sticky bits too), modification
+// times and symlinks. The snapshot is a
+// tar archive with the content of each file and an index, and it's written
+// atomically. With [SnapshotOptions.Previous] only the files that changed are
+// stored, making incremental snapshots.
+func Snapshot(root, snapshot string, opts SnapshotOptions) error {
+	return wrapErr("snapshot", root, snapshot, takeSnapshot(root, snapshot, opts))
+}
+
+func takeSnapshot(root, snapshot string, opts SnapshotOptions) error {
+	exists, err := Exists(snapshot)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errDestExists
+	}
+
+	index := SnapshotIndex{Created: time.Now()}
+	previous := map[string]SnapshotEntry{}
+	if opts.Previous != "" {
+		prev, err := readSnapshot(opts.Previous)
+		if err != nil {
+			return err
+		}
+		for _, e := range prev.Entries {
+			previous[e.Path] = e
+		}
+		if index.Previous, err = relativeTo(snapshot, opts.Previous); err != nil {
+			return err
+		}
+	}
+	if opts.Store != nil {
+		if index.Store, err = relativeTo(snapshot, opts.Store.root); err != nil {
+			return err
+		}
+	}
+
+	return writeAtomic(snapshot, 0644, func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if path != root && ignored(opts.Ignore, rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if path == root && !d.IsDir() {
+				return fmt.Errorf("%s is not a directory: %w", root, os.ErrInvalid)
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			e := SnapshotEntry{Path: filepath.ToSlash(rel), Mode: info.Mode(), ModTime: info.ModTime()}
+			switch {
+			case info.IsDir():
+			case info.Mode()&os.ModeSymlink != 0:
+				if e.Target, err = os.Readlink(path); err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				e.Size = info.Size()
+				if prev, ok := previous[e.Path]; ok && prev.Size == e.Size && prev.ModTime.Equal(e.ModTime) && prev.Digest != "" {
+					e.Digest = prev.Digest
+				} else if e.Digest, err = storeSnapshotFile(tw, opts.Store, path, e); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s is not a file, directory nor symlink: %w", path, os.ErrInvalid)
+			}
+			index.Entries = append(index.Entries, e)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(index, "", "\t")
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: snapshotIndexName, Mode: 0644, Size: int64(len(data)), ModTime: index.Created})
+		if err == nil {
+			_, err = tw.Write(data)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		return err
+	})
+}
+
+// storeSnapshotFile stores the content of the file at path in the store or, if
+// there's no store, in tw. It returns the digest of the content.
+func storeSnapshotFile(tw *tar.Writer, store *CAS, path string, e SnapshotEntry) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if store != nil {
+		return store.Put(f)
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: "files/" + e.Path, Mode: tarMode(e.Mode), Size: e.Size, ModTime: e.ModTime})
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	// The header has the size, so a file that grows or shrinks while it's read
+	// can't be stored.
+	if _, err := io.CopyN(io.MultiWriter(tw, h), f, e.Size); err != nil {
+		return "", fmt.Errorf("%s changed while it was read: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotPerm returns the bits of mode that are restored with chmod: the
+// permissions and the setuid, setgid and sticky bits.
+func snapshotPerm(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// tarMode returns the permissions of mode with the special bits encoded like
+// tar (and chmod) does.
+func tarMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// relativeTo returns path relative to the directory of snapshot, so snapshots
+// and stores can be moved together.
+func relativeTo(snapshot, path string) (string, error) {
+	rel, err := RelPath(filepath.Dir(snapshot), path)
+	return filepath.ToSlash(rel), err
+}
+
+// ReadSnapshot reads the index of a snapshot written by [Snapshot].
+func ReadSnapshot(snapshot string) (*SnapshotIndex, error) {
+	index, err := readSnapshot(snapshot)
+	return index, wrapErr("read", snapshot, "", err)
+}
+
+func readSnapshot(snapshot string) (*SnapshotIndex, error) {
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Since the file is seekable, the contents are skipped without reading
+	// them.
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s is not a snapshot: %w", snapshot, os.ErrInvalid)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Name != snapshotIndexName {
+			continue
+		}
+		var index SnapshotIndex
+		if err := json.NewDecoder(tr).Decode(&index); err != nil {
+			return nil, fmt.Errorf("%s is not a valid snapshot: %w", snapshot, err)
+		}
+		return &index, nil
+	}
+}
+
+// Restore recreates the tree saved in snapshot at dest (which must not exist),
+// taking the files that didn't change from the previous snapshots and the
+// store as needed. Every file is verified while it's written and, if it
+// doesn't match the digest it was saved with, Restore returns an [ErrChecksum]
+// error. The tree is restored in a temporary directory next to dest and renamed
+// at the end, so dest never holds a partially restored tree.
+func Restore(snapshot, dest string) error {
+	return wrapErr("restore", snapshot, dest, restore(snapshot, dest))
+}
+
+func restore(snapshot, dest string) error {
+	index, err := readSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	exists, err := Lexists(dest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errDestExists
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".restore*")
+	if err != nil {
+		return err
+	}
+	if err := restoreSnapshot(snapshot, index, tmp); err != nil {
+		// Restored directories may be read-only.
+		filepath.WalkDir(tmp, func(path string, d os.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				os.Chmod(path, 0700)
+			}
+			return nil
+		})
+		os.RemoveAll(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+func restoreSnapshot(snapshot string, index *SnapshotIndex, dest string) error {
+	// Paths of the files to restore by digest.
+	missing := map[string][]string{}
+	for _, e := range index.Entries {
+		path := filepath.Join(dest, filepath.FromSlash(e.Path))
+		var err error
+		switch {
+		case e.Path == ".":
+			if !e.Mode.IsDir() {
+				err = fmt.Errorf("the root of %s is not a directory: %w", snapshot, os.ErrInvalid)
+			}
+		case e.Mode.IsDir():
+			err = os.Mkdir(path, 0700)
+		case e.Mode&os.ModeSymlink != 0:
+			err = os.Symlink(e.Target, path)
+		default:
+			missing[e.Digest] = append(missing[e.Digest], path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for current, idx := snapshot, index; len(missing) > 0; {
+		if err := restoreFromStore(current, idx, missing); err != nil {
+			return err
+		}
+		if err := restoreFromTar(current, idx, missing); err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			break
+		}
+		if idx.Previous == "" {
+			return fmt.Errorf("%d files of %s aren't stored in it nor it's previous snapshots: %w", len(missing), snapshot, os.ErrNotExist)
+		}
+		current = filepath.Join(filepath.Dir(current), filepath.FromSlash(idx.Previous))
+		var err error
+		if idx, err = readSnapshot(current); err != nil {
+			return err
+		}
+	}
+
+	// Modes and times are restored once every entry is created, so read-only
+	// directories can be populated and their times aren't changed by the
+	// entries created inside them. Entries are walked deepest first, so a
+	// directory without write or search permission doesn't block its children.
+	for i := len(index.Entries) - 1; i >= 0; i-- {
+		e := index.Entries[i]
+		if e.Mode&os.ModeSymlink != 0 {
+			continue
+		}
+		path := filepath.Join(dest, filepath.FromSlash(e.Path))
+		if err := os.Chmod(path, snapshotPerm(e.Mode)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, e.ModTime, e.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFromStore restores the missing files whose content is stored in the
+// content-addressable store of snapshot, removing them from missing.
+func restoreFromStore(snapshot string, index *SnapshotIndex, missing map[string][]string) error {
+	if index.Store == "" {
+		return nil
+	}
+	store := &CAS{filepath.Join(filepath.Dir(snapshot), filepath.FromSlash(index.Store))}
+	for digest, paths := range missing {
+		r, err := store.get(digest)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// It may be in a previous snapshot.
+				continue
+			}
+			return err
+		}
+		err = restoreFile(r, digest, paths)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		delete(missing, digest)
+	}
+	return nil
+}
+
+// restoreFromTar restores the missing files whose content is stored in the tar
+// of snapshot, removing them from missing.
+func restoreFromTar(snapshot string, index *SnapshotIndex, missing map[string][]string) error {
+	digests := map[string]string{}
+	for _, e := range index.Entries {
+		digests["files/"+e.Path] = e.Digest
+	}
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for len(missing) > 0 {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		digest := digests[h.Name]
+		if paths, ok := missing[digest]; ok && digest != "" {
+			if err := restoreFile(tr, digest, paths); err != nil {
+				return err
+			}
+			delete(missing, digest)
+		}
+	}
+	return nil
+}
+
+// restoreFile writes the content of r to the first path, checking it matches
+// digest, and copies it to the rest of paths.
+func restoreFile(r io.Reader, digest string, paths []string) error {
+	f, err := os.OpenFile(paths[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != digest {
+		return fmt.Errorf("%s: %w", paths[0], ErrChecksum)
+	}
+	for _, path := range paths[1:] {
+		if _, err := CopyFile(paths[0], path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+// snapshotFixture drops the modification times of symlinks, which aren't
+// restored.
+func snapshotFixture(t *testing.T, root string) fs.Fixture {
+	t.Helper()
+	f, err := fs.ReadFixture(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, e := range f {
+		if e.Target != "" {
+			e.ModTime = time.Time{}
+			f[p] = e
+		}
+	}
+	return f
+}
+
+func TestSnapshot(t *testing.T) {
+	for _, withStore := range []bool{false, true} {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src")
+		old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		err := fs.Fixture{
+			"a.txt":          fs.FileEntry("hello").WithModTime(old),
+			"bin/run.sh":     fs.FileEntry("#!/bin/sh").WithMode(0755).WithModTime(old),
+			"ro/":            fs.DirEntry().WithMode(0555).WithModTime(old),
+			"link":           fs.SymlinkEntry("a.txt"),
+			"build/skip.txt": fs.FileEntry("skipped"),
+		}.Create(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := fs.SnapshotOptions{Ignore: []string{"build"}}
+		if withStore {
+			if opts.Store, err = fs.OpenCAS(filepath.Join(dir, "store")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		full := filepath.Join(dir, "full.snap")
+		if err := fs.Snapshot(src, full, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Snapshot(src, full, opts); !errors.Is(err, os.ErrExist) {
+			t.Errorf("expected an exist error, got %v", err)
+		}
+		want := snapshotFixture(t, src)
+		delete(want, "build")
+		delete(want, "build/skip.txt")
+		if err := fs.Restore(full, filepath.Join(dir, "restored")); err != nil {
+			t.Fatal(err)
+		}
+		fs.AssertTree(t, filepath.Join(dir, "restored"), want)
+
+		// Change a file and take an incremental snapshot.
+		if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts.Previous = full
+		incremental := filepath.Join(dir, "incremental.snap")
+		if err := fs.Snapshot(src, incremental, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !withStore {
+			fullInfo, _ := os.Stat(full)
+			incInfo, _ := os.Stat(incremental)
+			if incInfo.Size() >= fullInfo.Size() {
+				t.Errorf("incremental snapshot (%d bytes) is not smaller than the full one (%d bytes)", incInfo.Size(), fullInfo.Size())
+			}
+		}
+		want = snapshotFixture(t, src)
+		delete(want, "build")
+		delete(want, "build/skip.txt")
+		if err := fs.Restore(incremental, filepath.Join(dir, "restored2")); err != nil {
+			t.Fatal(err)
+		}
+		fs.AssertTree(t, filepath.Join(dir, "restored2"), want)
+
+		// Restored trees and sources have read-only directories.
+		for _, root := range []string{src, filepath.Join(dir, "restored"), filepath.Join(dir, "restored2")} {
+			os.Chmod(filepath.Join(root, "ro"), 0755)
+		}
+	}
+}
+
+func TestSnapshotSpecialBits(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	modes := map[string]os.FileMode{
+		"shared":  0777 | os.ModeSticky,
+		"group":   0775 | os.ModeSetgid,
+		"tool.sh": 0755 | os.ModeSetuid,
+	}
+	err := fs.Fixture{
+		"shared/": fs.DirEntry(),
+		"group/":  fs.DirEntry(),
+		"tool.sh": fs.FileEntry("#!/bin/sh"),
+	}.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range modes {
+		if err := os.Chmod(filepath.Join(src, name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := filepath.Join(dir, "src.snap")
+	if err := fs.Snapshot(src, snapshot, fs.SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	restored := filepath.Join(dir, "restored")
+	if err := fs.Restore(snapshot, restored); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range modes {
+		info, err := os.Stat(filepath.Join(restored, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() &^ os.ModeType; got != mode {
+			t.Errorf("expected %s to have mode %v, got %v", name, mode, got)
+		}
+	}
+}
+
+func TestSnapshotChainStores(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	err := fs.Fixture{
+		"a.txt":         fs.FileEntry("a"),
+		"locked/":       fs.DirEntry().WithMode(0500),
+		"locked/b.txt":  fs.FileEntry("b").WithMode(0400),
+		"changed.txt":   fs.FileEntry("old"),
+		"sub/unchanged": fs.FileEntry("unchanged"),
+	}.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.RemoveAll(dir) })
+
+	// Each snapshot stores its files in its own store, so the unchanged
+	// ones are only in the store of the first.
+	full := filepath.Join(dir, "full.snap")
+	first, err := fs.OpenCAS(filepath.Join(dir, "store1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Snapshot(src, full, fs.SnapshotOptions{Store: first}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "changed.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := fs.OpenCAS(filepath.Join(dir, "store2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	incremental := filepath.Join(dir, "incremental.snap")
+	if err := fs.Snapshot(src, incremental, fs.SnapshotOptions{Previous: full, Store: second}); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := filepath.Join(dir, "restored")
+	if err := fs.Restore(incremental, restored); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, restored, snapshotFixture(t, src))
+}
+
+func TestSnapshotFileRoot(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := fs.Snapshot(file, filepath.Join(dir, "file.snap"), fs.SnapshotOptions{})
+	if !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+}
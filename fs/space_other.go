@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package fs
+
+import (
+	"errors"
+	"os"
+)
+
+// FreeSpace is only supported on Linux and macOS, elsewhere it returns an
+// [errors.ErrUnsupported] error.
+func FreeSpace(path string) (uint64, error) {
+	return 0, &os.PathError{Op: "statfs", Path: path, Err: errors.ErrUnsupported}
+}
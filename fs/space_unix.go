@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to unprivileged users in the file
+// system where path lives. It's only supported on Linux and macOS, elsewhere it
+// returns an [errors.ErrUnsupported] error.
+func FreeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,203 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrChecksum is returned when some content doesn't match the hash it was
+// stored with.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Manifest describes a file split by [Split] so [Join] can reassemble it.
+type Manifest struct {
+	Name      string  `json:"name"` // Base name of the original file
+	Size      int64   `json:"size"`
+	SHA256    string  `json:"sha256"`
+	ChunkSize int64   `json:"chunkSize"`
+	Chunks    []Chunk `json:"chunks"`
+}
+
+// Chunk is a part of a split file. Name is relative to the directory of the
+// manifest.
+type Chunk struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ReadManifest reads a manifest written by [Split].
+func ReadManifest(path string) (*Manifest, error) {
+	m, err := readManifest(path)
+	return m, wrapErr("read", path, "", err)
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s is not a valid manifest: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Split splits the file at path into chunks of chunkSize bytes (the last one
+// may be smaller) so it can be uploaded to stores with a size limit. Chunks
+// are written next to the file with a numbered suffix (path.part0001,
+// path.part0002...) and a JSON manifest with the SHA-256 of each chunk and of
+// the whole file is written to path+".manifest". It returns the path of the
+// manifest. Like [CopyFile], the file is streamed so memory use stays constant
+// no matter it's size, and existing chunks aren't replaced. If it fails, the
+// chunks it wrote are removed.
+func Split(path string, chunkSize int64) (string, error) {
+	manifest, err := split(path, chunkSize)
+	return manifest, wrapErr("split", path, "", err)
+}
+
+func split(path string, chunkSize int64) (string, error) {
+	if chunkSize <= 0 {
+		return "", fmt.Errorf("invalid chunk size %d: %w", chunkSize, os.ErrInvalid)
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("source is a directory: %w", os.ErrInvalid)
+	}
+
+	m := Manifest{Name: filepath.Base(path), Size: info.Size(), ChunkSize: chunkSize}
+	// The chunks written are removed if the split fails.
+	removeChunks := func() {
+		for _, c := range m.Chunks {
+			os.Remove(filepath.Join(filepath.Dir(path), c.Name))
+		}
+	}
+	whole := sha256.New()
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.part%04d", filepath.Base(path), i)
+		chunk, err := writeChunk(filepath.Join(filepath.Dir(path), name), io.TeeReader(src, whole), chunkSize)
+		if err != nil {
+			removeChunks()
+			return "", err
+		}
+		if chunk.Size == 0 {
+			os.Remove(filepath.Join(filepath.Dir(path), name))
+			break
+		}
+		chunk.Name = name
+		m.Chunks = append(m.Chunks, chunk)
+		if chunk.Size < chunkSize {
+			break
+		}
+	}
+	m.SHA256 = hex.EncodeToString(whole.Sum(nil))
+
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		removeChunks()
+		return "", err
+	}
+	manifest := path + ".manifest"
+	if err := WriteFileAtomic(manifest, data, 0644); err != nil {
+		removeChunks()
+		return "", err
+	}
+	return manifest, nil
+}
+
+// writeChunk copies up to size bytes of r to a new file at path, which is
+// removed if it fails.
+func writeChunk(path string, r io.Reader, size int64) (Chunk, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return Chunk{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.CopyN(io.MultiWriter(f, h), r, size)
+	if err == nil || errors.Is(err, io.EOF) {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(path)
+		return Chunk{}, err
+	}
+	return Chunk{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Join reassembles the file described by the manifest written by [Split] into
+// dest. Each chunk is verified while it's copied and, if any chunk (or the
+// whole file) doesn't match it's hash, Join returns an [ErrChecksum] error. The
+// file is written atomically, so dest never holds a partially joined or
+// corrupted file. Like [CopyFile], it returns an [os.ErrExist] error if dest
+// exists.
+func Join(manifest, dest string) error {
+	return wrapErr("join", manifest, dest, join(manifest, dest))
+}
+
+func join(manifest, dest string) error {
+	m, err := readManifest(manifest)
+	if err != nil {
+		return err
+	}
+	destExists, err := Exists(dest)
+	if err != nil {
+		return err
+	}
+	if destExists {
+		return errDestExists
+	}
+
+	dir := filepath.Dir(manifest)
+	return writeAtomic(dest, 0644, func(w io.Writer) error {
+		whole := sha256.New()
+		var size int64
+		for _, c := range m.Chunks {
+			n, err := copyChunk(io.MultiWriter(w, whole), filepath.Join(dir, c.Name), c)
+			if err != nil {
+				return err
+			}
+			size += n
+		}
+		if size != m.Size || hex.EncodeToString(whole.Sum(nil)) != m.SHA256 {
+			return fmt.Errorf("joined file doesn't match %s: %w", manifest, ErrChecksum)
+		}
+		return nil
+	})
+}
+
+// copyChunk copies the chunk at path to w checking it's size and hash.
+func copyChunk(w io.Writer, path string, c Chunk) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// A chunk bigger than it should be is detected without copying all of it.
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(f, c.Size+1))
+	if err != nil {
+		return n, err
+	}
+	if n != c.Size || hex.EncodeToString(h.Sum(nil)) != c.SHA256 {
+		return n, fmt.Errorf("chunk %s: %w", path, ErrChecksum)
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package fs_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestSplitJoin(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.bin")
+	content := bytes.Repeat([]byte("0123456789"), 25)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := fs.Split(path, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := fs.ReadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Chunks) != 3 || m.Chunks[2].Size != 50 || m.Chunks[0].Name != "artifact.bin.part0001" {
+		t.Errorf("unexpected chunks: %+v", m.Chunks)
+	}
+
+	dest := filepath.Join(dir, "joined.bin")
+	if err := fs.Join(manifest, dest); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, content) {
+		t.Error("joined file differs from the original")
+	}
+	if err := fs.Join(manifest, dest); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected os.ErrExist, got %v", err)
+	}
+
+	// A corrupted chunk is detected and nothing is written.
+	corrupted := filepath.Join(dir, "corrupted.bin")
+	if err := os.WriteFile(filepath.Join(dir, m.Chunks[1].Name), bytes.Repeat([]byte("x"), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Join(manifest, corrupted); !errors.Is(err, fs.ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+	if exists, _ := fs.Exists(corrupted); exists {
+		t.Error("a corrupted file was written")
+	}
+
+	// So is a chunk bigger than it should be.
+	if err := os.WriteFile(filepath.Join(dir, m.Chunks[1].Name), bytes.Repeat([]byte("x"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Join(manifest, corrupted); !errors.Is(err, fs.ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestSplitFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact.bin")
+
+	// The second chunk exists, so the split fails after writing the first.
+	err := fs.Fixture{
+		"artifact.bin":          fs.FileEntry(strings.Repeat("0123456789", 25)),
+		"artifact.bin.part0002": fs.FileEntry("not ours"),
+	}.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Split(path, 100); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected an exist error, got %v", err)
+	}
+	fs.AssertTree(t, dir, fs.Fixture{
+		"artifact.bin":          fs.FileEntry(strings.Repeat("0123456789", 25)),
+		"artifact.bin.part0002": fs.FileEntry("not ours"),
+	})
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package fs
+
+import "os"
+
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// inodeOf is not supported outside unix systems, so hard links can't be
+// detected and every link is counted as a separate file.
+func inodeOf(info os.FileInfo) (fileKey, uint64, bool) {
+	return fileKey{}, 0, false
+}
+
+// ownerOf is not supported outside unix systems, so ownership can't be
+// audited.
+func ownerOf(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
+
+// allocatedSize falls back to the apparent size since there's no portable way
+// to get the allocated blocks.
+func allocatedSize(info os.FileInfo) int64 {
+	return info.Size()
+}
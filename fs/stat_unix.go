@@ -0,0 +1,46 @@
+//go:build unix
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file inside a machine, it's used to detect hard links
+// so they aren't counted twice.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// inodeOf returns the device and inode pair of info and the number of hard
+// links pointing to it. The bool is false if the platform doesn't expose it.
+func inodeOf(info os.FileInfo) (fileKey, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, 0, false
+	}
+	return fileKey{uint64(st.Dev), uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// ownerOf returns the user and group ids that own info. The bool is false if
+// the platform doesn't expose them.
+func ownerOf(info os.FileInfo) (int, int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// allocatedSize returns the bytes actually reserved on disk for info, which
+// may be smaller than the apparent size for sparse files and bigger for small
+// ones.
+func allocatedSize(info os.FileInfo) int64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return int64(st.Blocks) * 512
+}
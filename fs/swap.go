@@ -0,0 +1,272 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+var errExchangeUnsupported = errors.New("atomic exchange not supported")
+
+// ErrNoGeneration is returned by [RollbackDir] when there's no previous
+// generation to go back to.
+var ErrNoGeneration = errors.New("no previous generation")
+
+// ReplaceOptions tunes [ReplaceDir]. The zero value keeps a single previous
+// generation.
+type ReplaceOptions struct {
+	// Keep is the number of previous generations kept after a replacement.
+	// If it's 0, 1 generation is kept. A negative value keeps none, so
+	// [RollbackDir] won't be able to go back.
+	Keep int
+	// Symlink uses the symlink flip strategy even if target is a regular
+	// directory. It's ignored if target is already a symlink, since then the
+	// symlink flip strategy is always used.
+	Symlink bool
+}
+
+func (o ReplaceOptions) keep() int {
+	if o.Keep == 0 {
+		return 1
+	}
+	return max(o.Keep, 0)
+}
+
+// generationsDir returns the hidden directory next to target where the
+// previous generations are stored (and, with the symlink strategy, the current
+// one too).
+func generationsDir(target string) string {
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".generations")
+}
+
+// generations returns the numbers of the generations of target sorted from
+// oldest to newest.
+func generations(target string) ([]int, error) {
+	entries, err := os.ReadDir(generationsDir(target))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var gens []int
+	for _, e := range entries {
+		if n, err := strconv.Atoi(e.Name()); err == nil {
+			gens = append(gens, n)
+		}
+	}
+	sort.Ints(gens)
+	return gens, nil
+}
+
+func generationPath(target string, n int) string {
+	return filepath.Join(generationsDir(target), fmt.Sprintf("%06d", n))
+}
+
+// nextGeneration creates the generations directory if needed and returns the
+// path of a new generation.
+func nextGeneration(target string) (string, error) {
+	if err := os.MkdirAll(generationsDir(target), 0755); err != nil {
+		return "", err
+	}
+	gens, err := generations(target)
+	if err != nil {
+		return "", err
+	}
+	n := 1
+	if len(gens) > 0 {
+		n = gens[len(gens)-1] + 1
+	}
+	return generationPath(target, n), nil
+}
+
+// ReplaceDir swaps the fully prepared staging directory into target (which
+// may not exist yet) so readers of target either see the previous version or
+// the new one, never a mix of both. The previous version is kept in a hidden
+// directory next to target (".<name>.generations") and [RollbackDir] can bring
+// it back. staging should be in the same file system as target, otherwise it
+// has to be copied first and the replacement is no longer atomic.
+//
+// There are 2 strategies:
+//
+//   - If target is a regular directory, on Linux it's exchanged with staging
+//     using renameat2 with the RENAME_EXCHANGE flag. Elsewhere (or if the file
+//     system doesn't support it) target is renamed away and staging is renamed
+//     into it's place, which leaves a tiny window where target doesn't exist.
+//   - If target is a symlink (or [ReplaceOptions.Symlink] is set) staging is
+//     moved into the generations directory and target is replaced by a symlink
+//     pointing to it. Replacing a symlink with a rename is atomic in every
+//     POSIX system, so this strategy is the portable one.
+func ReplaceDir(staging, target string, opts ReplaceOptions) error {
+	return wrapErr("replace", staging, target, replaceDir(staging, target, opts))
+}
+
+func replaceDir(staging, target string, opts ReplaceOptions) error {
+	isDir, err := IsDir(staging)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return fmt.Errorf("%s is not a directory: %w", staging, os.ErrInvalid)
+	}
+	kind, err := Lstat(target)
+	if err != nil {
+		return err
+	}
+
+	if kind == KindSymlink || (kind == KindMissing && opts.Symlink) {
+		return replaceSymlink(staging, target, opts)
+	}
+	if kind == KindMissing {
+		return os.Rename(staging, target)
+	}
+	if kind != KindDir {
+		return fmt.Errorf("%s is not a directory: %w", target, os.ErrInvalid)
+	}
+	if opts.Symlink {
+		// Convert target into a generation so the symlink can replace it.
+		gen, err := nextGeneration(target)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(target, gen); err != nil {
+			return err
+		}
+		if err := flipSymlink(gen, target); err != nil {
+			return err
+		}
+		return replaceSymlink(staging, target, opts)
+	}
+
+	gen, err := nextGeneration(target)
+	if err != nil {
+		return err
+	}
+	err = exchange(staging, target)
+	switch {
+	case err == nil:
+		// staging holds the previous version now.
+		err = os.Rename(staging, gen)
+	case errors.Is(err, errExchangeUnsupported):
+		if err = os.Rename(target, gen); err == nil {
+			err = os.Rename(staging, target)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return pruneGenerations(target, opts.keep())
+}
+
+func replaceSymlink(staging, target string, opts ReplaceOptions) error {
+	gen, err := nextGeneration(target)
+	if err != nil {
+		return err
+	}
+	if err := move(staging, gen); err != nil {
+		return err
+	}
+	if err := flipSymlink(gen, target); err != nil {
+		return err
+	}
+	// The current generation lives in the generations directory too.
+	return pruneGenerations(target, opts.keep()+1)
+}
+
+// flipSymlink atomically points the target symlink to gen, creating the new
+// symlink aside and renaming it over the old one.
+func flipSymlink(gen, target string) error {
+	rel, err := filepath.Rel(filepath.Dir(target), gen)
+	if err != nil {
+		return err
+	}
+	tmp := target + ".tmp-link"
+	os.Remove(tmp)
+	if err := os.Symlink(rel, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pruneGenerations removes the oldest generations of target until only keep
+// remain.
+func pruneGenerations(target string, keep int) error {
+	gens, err := generations(target)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(gens)-keep; i++ {
+		if err := os.RemoveAll(generationPath(target, gens[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackDir undoes the last [ReplaceDir] on target, bringing back the
+// previous generation and discarding the current one. It can be called
+// repeatedly to go further back as long as there are generations left, once
+// there are none, it returns [ErrNoGeneration].
+func RollbackDir(target string) error {
+	return wrapErr("rollback", "", target, rollbackDir(target))
+}
+
+func rollbackDir(target string) error {
+	kind, err := Lstat(target)
+	if err != nil {
+		return err
+	}
+	gens, err := generations(target)
+	if err != nil {
+		return err
+	}
+
+	if kind == KindSymlink {
+		link, err := os.Readlink(target)
+		if err != nil {
+			return err
+		}
+		current, err := strconv.Atoi(filepath.Base(link))
+		if err != nil {
+			return fmt.Errorf("%s doesn't point to a generation: %w", target, os.ErrInvalid)
+		}
+		prev := -1
+		for _, g := range gens {
+			if g < current {
+				prev = g
+			}
+		}
+		if prev < 0 {
+			return ErrNoGeneration
+		}
+		if err := flipSymlink(generationPath(target, prev), target); err != nil {
+			return err
+		}
+		return os.RemoveAll(generationPath(target, current))
+	}
+
+	if len(gens) == 0 {
+		return ErrNoGeneration
+	}
+	prev := generationPath(target, gens[len(gens)-1])
+	err = exchange(prev, target)
+	if errors.Is(err, errExchangeUnsupported) {
+		discarded := prev + ".discarded"
+		if err = os.Rename(target, discarded); err == nil {
+			err = os.Rename(prev, target)
+			prev = discarded
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// prev holds the discarded version now.
+	return os.RemoveAll(prev)
+}
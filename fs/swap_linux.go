@@ -0,0 +1,44 @@
+//go:build linux && (amd64 || arm64)
+
+package fs
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// renameat2 isn't in the syscall package, these are it's numbers in the
+// supported architectures.
+var sysRenameat2 = map[string]uintptr{"amd64": 316, "arm64": 276}[runtime.GOARCH]
+
+const (
+	atFdcwd        = -0x64 // AT_FDCWD, paths are relative to the working directory
+	renameExchange = 0x2   // RENAME_EXCHANGE flag of renameat2
+)
+
+// exchange atomically swaps the entries at a and b using renameat2. It returns
+// errExchangeUnsupported if the kernel or the file system don't support it.
+func exchange(a, b string) error {
+	pa, err := syscall.BytePtrFromString(a)
+	if err != nil {
+		return err
+	}
+	pb, err := syscall.BytePtrFromString(b)
+	if err != nil {
+		return err
+	}
+	cwd := atFdcwd
+	_, _, errno := syscall.Syscall6(sysRenameat2,
+		uintptr(cwd), uintptr(unsafe.Pointer(pa)),
+		uintptr(cwd), uintptr(unsafe.Pointer(pb)),
+		renameExchange, 0)
+	switch errno {
+	case 0:
+		return nil
+	case syscall.ENOSYS, syscall.EINVAL:
+		return errExchangeUnsupported
+	}
+	return &os.LinkError{Op: "renameat2", Old: a, New: b, Err: errno}
+}
@@ -0,0 +1,8 @@
+//go:build !linux || !(amd64 || arm64)
+
+package fs
+
+// exchange isn't supported outside linux, ReplaceDir falls back to 2 renames.
+func exchange(a, b string) error {
+	return errExchangeUnsupported
+}
@@ -0,0 +1,59 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestReplaceDir(t *testing.T) {
+	for _, symlink := range []bool{false, true} {
+		root := t.TempDir()
+		target := filepath.Join(root, "current")
+		opts := fs.ReplaceOptions{Keep: 2, Symlink: symlink}
+
+		deploy := func(version string) {
+			t.Helper()
+			staging := filepath.Join(root, "staging-"+version)
+			if err := (fs.Fixture{"version": fs.FileEntry(version)}).Create(staging); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.ReplaceDir(staging, target, opts); err != nil {
+				t.Fatalf("symlink=%v: deploying %s: %v", symlink, version, err)
+			}
+			if exists, _ := fs.Exists(staging); exists {
+				t.Errorf("symlink=%v: staging-%s was not moved", symlink, version)
+			}
+		}
+		expect := func(version string) {
+			t.Helper()
+			got, err := os.ReadFile(filepath.Join(target, "version"))
+			if err != nil || string(got) != version {
+				t.Errorf("symlink=%v: expected version %s, got %q (%v)", symlink, version, got, err)
+			}
+		}
+
+		for _, v := range []string{"v1", "v2", "v3", "v4"} {
+			deploy(v)
+			expect(v)
+		}
+		if isLink, _ := fs.IsSymlink(target); isLink != symlink {
+			t.Errorf("symlink=%v: target is a symlink: %v", symlink, isLink)
+		}
+
+		// Only 2 previous generations are kept.
+		for _, v := range []string{"v3", "v2"} {
+			if err := fs.RollbackDir(target); err != nil {
+				t.Fatalf("symlink=%v: %v", symlink, err)
+			}
+			expect(v)
+		}
+		if err := fs.RollbackDir(target); !errors.Is(err, fs.ErrNoGeneration) {
+			t.Errorf("symlink=%v: expected ErrNoGeneration, got %v", symlink, err)
+		}
+		expect("v2")
+	}
+}
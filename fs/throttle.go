@@ -0,0 +1,108 @@
+package fs
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a budget of bytes and operations per second shared by every
+// reader and writer throttled with it, so a single Limiter caps the total
+// throughput of several concurrent copies (eg: to keep background copies from
+// saturating a disk used by other services). Budgets are token buckets: up to
+// a second of unused budget is saved, so short bursts aren't slowed down. It's
+// safe for concurrent use.
+type Limiter struct {
+	bytesPerSec float64
+	opsPerSec   float64
+
+	mu    sync.Mutex
+	bytes float64 // Available budgets, negative when in debt
+	ops   float64
+	last  time.Time
+}
+
+// NewLimiter returns a Limiter that allows up to bytesPerSec bytes and
+// opsPerSec reads or writes per second. A limit of 0 (or less) disables it.
+func NewLimiter(bytesPerSec int64, opsPerSec int) *Limiter {
+	l := &Limiter{bytesPerSec: float64(bytesPerSec), opsPerSec: float64(opsPerSec), last: time.Now()}
+	l.bytes, l.ops = l.bytesPerSec, l.opsPerSec
+	return l
+}
+
+// wait takes n bytes and an operation from the budget, sleeping until they're
+// available.
+func (l *Limiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+
+	// The cost is taken even if there isn't enough budget, the debt is paid
+	// by sleeping, so big operations don't wait forever and callers waiting
+	// at the same time are served in turns.
+	var delay float64
+	if l.bytesPerSec > 0 {
+		l.bytes = min(l.bytes+elapsed*l.bytesPerSec, l.bytesPerSec) - float64(n)
+		delay = max(delay, -l.bytes/l.bytesPerSec)
+	}
+	if l.opsPerSec > 0 {
+		l.ops = min(l.ops+elapsed*l.opsPerSec, l.opsPerSec) - 1
+		delay = max(delay, -l.ops/l.opsPerSec)
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(time.Duration(delay * float64(time.Second)))
+	}
+}
+
+// maxChunk caps the size of each read or write to a second of budget, so
+// throttled streams flow steadily instead of in big bursts.
+func (l *Limiter) maxChunk(n int) int {
+	if l.bytesPerSec > 0 && float64(n) > l.bytesPerSec {
+		return max(int(l.bytesPerSec), 1)
+	}
+	return n
+}
+
+type throttledReader struct {
+	r io.Reader
+	l *Limiter
+}
+
+// NewThrottledReader returns a reader that reads from r within the budget of l.
+func NewThrottledReader(r io.Reader, l *Limiter) io.Reader {
+	return &throttledReader{r, l}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p[:t.l.maxChunk(len(p))])
+	t.l.wait(n)
+	return n, err
+}
+
+type throttledWriter struct {
+	w io.Writer
+	l *Limiter
+}
+
+// NewThrottledWriter returns a writer that writes to w within the budget of l.
+func NewThrottledWriter(w io.Writer, l *Limiter) io.Writer {
+	return &throttledWriter{w, l}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:t.l.maxChunk(len(p))]
+		t.l.wait(len(chunk))
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,65 @@
+package fs_test
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestLimiter(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/a": fs.FileEntry(string(make([]byte, 150_000))),
+		"src/b": fs.FileEntry(string(make([]byte, 150_000))),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first second of budget (200KB) is available right away, the
+	// remaining 100KB take half a second.
+	limiter := fs.NewLimiter(200_000, 0)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fs.CopyFileWithOptions(filepath.Join(root, "src", name), filepath.Join(root, name), fs.CopyOptions{Limiter: limiter})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected the copies to take around 500ms, they took %v", elapsed)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"src/a": fs.FileEntry(string(make([]byte, 150_000))),
+		"src/b": fs.FileEntry(string(make([]byte, 150_000))),
+		"a":     fs.FileEntry(string(make([]byte, 150_000))),
+		"b":     fs.FileEntry(string(make([]byte, 150_000))),
+	})
+
+	// 25 writes at 20 per second, the last 5 wait for a quarter of second.
+	var buf bytes.Buffer
+	w := fs.NewThrottledWriter(&buf, fs.NewLimiter(0, 20))
+	start = time.Now()
+	for range 25 {
+		if _, err := io.WriteString(w, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected the writes to take around 250ms, they took %v", elapsed)
+	}
+	if buf.Len() != 25 {
+		t.Errorf("expected 25 bytes, got %d", buf.Len())
+	}
+}
@@ -0,0 +1,347 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trashInfoExt        = ".trashinfo"
+	trashDeletionLayout = "2006-01-02T15:04:05"
+)
+
+// TrashItem is an entry in a trash directory.
+type TrashItem struct {
+	// Name is the name of the entry inside the trash, which may differ from
+	// the original one if several entries with the same name were trashed.
+	Name string
+	// Path is the absolute path the entry had before being trashed.
+	Path    string
+	Deleted time.Time
+
+	trash string
+}
+
+// Trash moves path (a file, directory or symlink) to the trash following the
+// freedesktop.org trash spec (https://specifications.freedesktop.org/trash-spec/latest/),
+// so it can be restored from the file manager or with [TrashItem.Restore].
+// Entries in the same file system as the home directory go to the trash in
+// [DataHome] (~/.local/share/Trash) and the rest to the trash at the top of
+// their file system ($topdir/.Trash/$uid or $topdir/.Trash-$uid). If the
+// latter can't be used, they're moved to the home trash, copying them across
+// file systems like [Move] does.
+func Trash(path string) error {
+	return wrapErr("trash", path, "", moveToTrash(path))
+}
+
+func moveToTrash(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	home, err := homeTrash()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return err
+	}
+	homeInfo, err := os.Stat(home)
+	if err != nil {
+		return err
+	}
+	trash, topdir := home, ""
+	if !sameDevice(info, homeInfo) {
+		if topdir, err = mountTop(path); err == nil {
+			trash, err = topdirTrash(topdir)
+		}
+		if err != nil {
+			trash, topdir = home, ""
+		}
+	}
+	for _, dir := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trash, dir), 0700); err != nil {
+			return err
+		}
+	}
+
+	// Trashes in other file systems store paths relative to their top
+	// directory, so they stay valid if it's mounted elsewhere.
+	stored := path
+	if topdir != "" {
+		if stored, err = filepath.Rel(topdir, path); err != nil {
+			return err
+		}
+	}
+	name, err := writeTrashInfo(trash, filepath.Base(path), stored)
+	if err != nil {
+		return err
+	}
+	if err := move(path, filepath.Join(trash, "files", name)); err != nil {
+		os.Remove(filepath.Join(trash, "info", name+trashInfoExt))
+		return err
+	}
+	return nil
+}
+
+// writeTrashInfo creates the .trashinfo file of a new entry, picking a free
+// name based on base, and returns that name. Since the info file is created
+// first with O_EXCL, concurrent calls never pick the same name.
+func writeTrashInfo(trash, base, path string) (string, error) {
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: filepath.ToSlash(path)}).EscapedPath(), time.Now().Format(trashDeletionLayout))
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		f, err := os.OpenFile(filepath.Join(trash, "info", name+trashInfoExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		// An entry may have been left in files without it's info file.
+		if exists, err := Lexists(filepath.Join(trash, "files", name)); err != nil || exists {
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		return name, nil
+	}
+}
+
+func homeTrash() (string, error) {
+	data, err := DataHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "Trash"), nil
+}
+
+// topdirTrash returns the trash of the file system mounted at topdir:
+// $topdir/.Trash/$uid if $topdir/.Trash is a sticky directory (and not a
+// symlink) or $topdir/.Trash-$uid otherwise.
+func topdirTrash(topdir string) (string, error) {
+	uid := strconv.Itoa(os.Getuid())
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		trash := filepath.Join(shared, uid)
+		if err := os.Mkdir(trash, 0700); err == nil || os.IsExist(err) {
+			return trash, nil
+		}
+	}
+	trash := filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.Mkdir(trash, 0700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	info, err := os.Lstat(trash)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory: %w", trash, os.ErrInvalid)
+	}
+	return trash, nil
+}
+
+// mountTop returns the top directory of the file system where path lives.
+func mountTop(path string) (string, error) {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		parentInfo, err := os.Stat(parent)
+		if err != nil {
+			return "", err
+		}
+		if !sameDevice(info, parentInfo) {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// sameDevice checks wether a and b are in the same file system, which is
+// always true on platforms without device numbers.
+func sameDevice(a, b os.FileInfo) bool {
+	keyA, _, okA := inodeOf(a)
+	keyB, _, okB := inodeOf(b)
+	return !okA || !okB || keyA.dev == keyB.dev
+}
+
+// trashDirs returns the home trash and the existing trashes of the mounted
+// file systems. Mount points are only known on Linux, elsewhere only the home
+// trash is returned.
+func trashDirs() ([]string, error) {
+	home, err := homeTrash()
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{home}
+	uid := strconv.Itoa(os.Getuid())
+	for _, mount := range mountPoints() {
+		for _, trash := range []string{filepath.Join(mount, ".Trash", uid), filepath.Join(mount, ".Trash-"+uid)} {
+			if trash == home {
+				continue
+			}
+			if info, err := os.Lstat(trash); err == nil && info.IsDir() {
+				dirs = append(dirs, trash)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// mountPoints returns the mount points listed in /proc/self/mounts, if it
+// exists.
+func mountPoints() []string {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		// Spaces and other special characters are escaped as octal (eg: \040).
+		if mount, err := strconv.Unquote(`"` + fields[1] + `"`); err == nil {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+// ListTrash returns the entries of every trash sorted from the most to the
+// least recently deleted. Entries whose info file can't be parsed are skipped.
+func ListTrash() ([]TrashItem, error) {
+	dirs, err := trashDirs()
+	if err != nil {
+		return nil, wrapErr("list", "", "", err)
+	}
+	var items []TrashItem
+	for _, trash := range dirs {
+		entries, err := os.ReadDir(filepath.Join(trash, "info"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, &Error{Op: "list", Source: trash, Err: err}
+		}
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), trashInfoExt)
+			if !ok {
+				continue
+			}
+			if item, err := readTrashInfo(trash, name); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) })
+	return items, nil
+}
+
+func readTrashInfo(trash, name string) (TrashItem, error) {
+	data, err := os.ReadFile(filepath.Join(trash, "info", name+trashInfoExt))
+	if err != nil {
+		return TrashItem{}, err
+	}
+	item := TrashItem{Name: name, trash: trash}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "Path":
+			if item.Path, err = url.PathUnescape(value); err != nil {
+				return TrashItem{}, err
+			}
+			item.Path = filepath.FromSlash(item.Path)
+		case "DeletionDate":
+			if item.Deleted, err = time.ParseInLocation(trashDeletionLayout, value, time.Local); err != nil {
+				return TrashItem{}, err
+			}
+		}
+	}
+	if item.Path == "" {
+		return TrashItem{}, fmt.Errorf("%s has no path: %w", name+trashInfoExt, os.ErrInvalid)
+	}
+	if !filepath.IsAbs(item.Path) {
+		// Relative to the top directory of the file system.
+		topdir := filepath.Dir(trash)
+		if filepath.Base(topdir) == ".Trash" {
+			topdir = filepath.Dir(topdir)
+		}
+		item.Path = filepath.Join(topdir, item.Path)
+	}
+	return item, nil
+}
+
+// Restore moves the entry back to it's original path, creating the missing
+// parent directories. Like [Move], it returns an [os.ErrExist] error if
+// something was created at that path in the meantime.
+func (i TrashItem) Restore() error {
+	source := filepath.Join(i.trash, "files", i.Name)
+	if err := os.MkdirAll(filepath.Dir(i.Path), 0755); err != nil {
+		return &Error{Op: "mkdir", Source: source, Dest: i.Path, Err: err}
+	}
+	if err := Move(source, i.Path); err != nil {
+		return err
+	}
+	return wrapErr("remove", source, i.Path, os.Remove(filepath.Join(i.trash, "info", i.Name+trashInfoExt)))
+}
+
+// Delete removes the entry from the trash permanently.
+func (i TrashItem) Delete() error {
+	path := filepath.Join(i.trash, "files", i.Name)
+	if err := os.RemoveAll(path); err != nil {
+		return &Error{Op: "remove", Source: path, Err: err}
+	}
+	return wrapErr("remove", path, "", os.Remove(filepath.Join(i.trash, "info", i.Name+trashInfoExt)))
+}
+
+// EmptyTrash permanently removes every entry of every trash (see [ListTrash]).
+func EmptyTrash() error {
+	items, err := ListTrash()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := item.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
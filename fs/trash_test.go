@@ -0,0 +1,75 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestTrash(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	err := fs.Fixture{
+		"docs/report.txt":          fs.FileEntry("v1"),
+		"docs/old/report.txt":      fs.FileEntry("v0"),
+		"docs/dir with space/file": fs.FileEntry("nested"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"docs/old/report.txt", "docs/report.txt", "docs/dir with space"} {
+		if err := fs.Trash(filepath.Join(root, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs.AssertTree(t, filepath.Join(root, "docs"), fs.Fixture{"old/": fs.DirEntry()})
+
+	items, err := fs.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]fs.TrashItem{}
+	for _, item := range items {
+		// Ignore the trashes of other file systems.
+		if strings.HasPrefix(item.Path, root) {
+			byPath[item.Path] = item
+		}
+	}
+	if len(byPath) != 3 {
+		t.Fatalf("expected 3 trashed entries, got %+v", items)
+	}
+	report := byPath[filepath.Join(root, "docs/report.txt")]
+	if report.Name != "report.txt.2" {
+		t.Errorf("expected the second report to be renamed in the trash, got %s", report.Name)
+	}
+	info, err := os.ReadFile(filepath.Join(root, "data/Trash/info/dir with space.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Path=" + filepath.ToSlash(filepath.Join(root, "docs")) + "/dir%20with%20space\n"; !strings.Contains(string(info), want) {
+		t.Errorf("expected %q in the info file, got %q", want, info)
+	}
+
+	if err := report.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if err := byPath[filepath.Join(root, "docs/dir with space")].Restore(); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, filepath.Join(root, "docs"), fs.Fixture{
+		"old/":                fs.DirEntry(),
+		"report.txt":          fs.FileEntry("v1"),
+		"dir with space/file": fs.FileEntry("nested"),
+	})
+
+	// EmptyTrash isn't used since it would empty the trashes of other file
+	// systems too.
+	if err := byPath[filepath.Join(root, "docs/old/report.txt")].Delete(); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, filepath.Join(root, "data/Trash"), fs.Fixture{"files/": fs.DirEntry(), "info/": fs.DirEntry()})
+}
@@ -0,0 +1,417 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TreeSort is the order used by [RenderTree] to list the entries of each
+// directory.
+type TreeSort int
+
+const (
+	SortByName TreeSort = iota
+	SortBySize
+	SortByModTime
+)
+
+// TreeOptions tunes the output of [RenderTree] and [WriteTree]. The zero value
+// renders the whole tree with box-drawing characters sorted by name.
+type TreeOptions struct {
+	// ASCII uses "|--" and "`--" instead of box-drawing characters.
+	ASCII bool
+	// Sizes and Perms print the size and the permissions of each entry
+	// between brackets before it's name.
+	Sizes bool
+	Perms bool
+	// MaxDepth is the number of levels printed below root like the -L flag
+	// of tree. 0 means no limit.
+	MaxDepth int
+	Sort     TreeSort
+	Reverse  bool
+	// DirsFirst lists directories before files no matter the sort order.
+	DirsFirst bool
+	// Ignore holds patterns of entries left out of the tree. Patterns without
+	// a slash are matched against the entry name (eg: "*.tmp") and the ones with
+	// a slash against the path relative to root (eg: "build/*"). The syntax is
+	// the one of [path.Match].
+	Ignore []string
+}
+
+type treeGlyphs struct {
+	branch, last, pipe, space string
+}
+
+var (
+	boxGlyphs   = treeGlyphs{"├── ", "└── ", "│   ", "    "}
+	asciiGlyphs = treeGlyphs{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// RenderTree returns the text representation of the root directory like the
+// tree command prints it. Directories end with a slash and symlinks show their
+// target after an arrow, so the output can be turned back into a directory with
+// [BuildTree]:
+//
+//	project/
+//	├── go.mod
+//	├── latest -> v2
+//	└── v2/
+//	    └── main.go
+func RenderTree(root string, opts TreeOptions) (string, error) {
+	var sb strings.Builder
+	if err := WriteTree(&sb, root, opts); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// WriteTree does the same as [RenderTree] but writes the tree to w line by line
+// instead of building it in memory.
+func WriteTree(w io.Writer, root string, opts TreeOptions) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return &Error{Op: "lstat", Source: root, Err: err}
+	}
+	bw := bufio.NewWriter(w)
+	glyphs := boxGlyphs
+	if opts.ASCII {
+		glyphs = asciiGlyphs
+	}
+
+	if _, err := fmt.Fprintln(bw, treeLine(root, info, opts)); err != nil {
+		return &Error{Op: "write", Source: root, Err: err}
+	}
+	if info.IsDir() {
+		if err := writeTreeDir(bw, root, "", "", 1, opts, glyphs); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return &Error{Op: "write", Source: root, Err: err}
+	}
+	return nil
+}
+
+func writeTreeDir(w io.Writer, root, rel, prefix string, depth int, opts TreeOptions, glyphs treeGlyphs) error {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return nil
+	}
+	dirEntries, err := os.ReadDir(filepath.Join(root, rel))
+	if err != nil {
+		return &Error{Op: "readdir", Source: filepath.Join(root, rel), Err: err}
+	}
+
+	type entry struct {
+		rel  string
+		info os.FileInfo
+	}
+	entries := make([]entry, 0, len(dirEntries))
+	for _, e := range dirEntries {
+		entryRel := filepath.Join(rel, e.Name())
+		if ignored(opts.Ignore, entryRel) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return &Error{Op: "lstat", Source: filepath.Join(root, entryRel), Err: err}
+		}
+		entries = append(entries, entry{entryRel, info})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].info, entries[j].info
+		if opts.DirsFirst && a.IsDir() != b.IsDir() {
+			return a.IsDir()
+		}
+		if opts.Reverse {
+			a, b = b, a
+		}
+		switch opts.Sort {
+		case SortBySize:
+			if a.Size() != b.Size() {
+				return a.Size() < b.Size()
+			}
+		case SortByModTime:
+			if !a.ModTime().Equal(b.ModTime()) {
+				return a.ModTime().Before(b.ModTime())
+			}
+		}
+		return a.Name() < b.Name()
+	})
+
+	for i, e := range entries {
+		branch, indent := glyphs.branch, glyphs.pipe
+		if i == len(entries)-1 {
+			branch, indent = glyphs.last, glyphs.space
+		}
+		if _, err := fmt.Fprintln(w, prefix+branch+treeLine(filepath.Join(root, e.rel), e.info, opts)); err != nil {
+			return &Error{Op: "write", Source: root, Err: err}
+		}
+		if e.info.IsDir() {
+			err := writeTreeDir(w, root, e.rel, prefix+indent, depth+1, opts, glyphs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// treeLine formats a single entry (without the branch glyphs).
+func treeLine(path string, info os.FileInfo, opts TreeOptions) string {
+	var meta []string
+	if opts.Perms {
+		meta = append(meta, permString(info.Mode()))
+	}
+	if opts.Sizes {
+		meta = append(meta, fmt.Sprintf("%10d", info.Size()))
+	}
+
+	var sb strings.Builder
+	if len(meta) > 0 {
+		fmt.Fprintf(&sb, "[%s]  ", strings.Join(meta, " "))
+	}
+	sb.WriteString(filepath.Base(path))
+	switch {
+	case info.IsDir():
+		sb.WriteString("/")
+	case info.Mode()&os.ModeSymlink != 0:
+		if target, err := os.Readlink(path); err == nil {
+			sb.WriteString(" -> " + target)
+		}
+	}
+	return sb.String()
+}
+
+// permString formats mode like ls does (eg: drwxr-xr-x), setuid, setgid and
+// sticky bits aren't shown.
+func permString(mode os.FileMode) string {
+	kind := "-"
+	switch {
+	case mode.IsDir():
+		kind = "d"
+	case mode&os.ModeSymlink != 0:
+		kind = "l"
+	}
+	return kind + mode.Perm().String()[1:]
+}
+
+func parsePerm(s string) (os.FileMode, error) {
+	if len(s) != 10 {
+		return 0, fmt.Errorf("invalid permissions %q: %w", s, os.ErrInvalid)
+	}
+	var mode os.FileMode
+	for i, c := range s[1:] {
+		if c != '-' {
+			mode |= 1 << (8 - i)
+		}
+	}
+	return mode, nil
+}
+
+// TreeNode is an entry of a tree parsed by [ParseTree]. Mode and Size are only
+// set if the text included them.
+type TreeNode struct {
+	Name     string
+	Dir      bool
+	Target   string // Target of the symlink, empty if it's not a symlink
+	Mode     os.FileMode
+	Size     int64
+	Children []*TreeNode
+}
+
+// ParseTree parses the output of [RenderTree] (either with box-drawing or ASCII
+// characters) and returns it's root node. Entries are considered directories if
+// they end with a slash or have children.
+func ParseTree(r io.Reader) (*TreeNode, error) {
+	scanner := bufio.NewScanner(r)
+	var root *TreeNode
+	var stack []*TreeNode // stack[i] is the last node seen at depth i
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		depth := 0
+		if root != nil {
+			var ok bool
+			depth, line, ok = trimTreePrefix(line)
+			if !ok {
+				return nil, fmt.Errorf("line %d: missing tree branch: %w", lineNum, os.ErrInvalid)
+			}
+		}
+		node, err := parseTreeLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		if root == nil {
+			root = node
+			stack = []*TreeNode{root}
+			continue
+		}
+		if depth > len(stack) {
+			return nil, fmt.Errorf("line %d: entry is nested too deep: %w", lineNum, os.ErrInvalid)
+		}
+		parent := stack[depth-1]
+		parent.Dir = true
+		parent.Children = append(parent.Children, node)
+		stack = append(stack[:depth], node)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("empty tree: %w", os.ErrInvalid)
+	}
+	return root, nil
+}
+
+// trimTreePrefix removes the indentation and the branch glyphs of a line
+// returning the depth of the entry.
+func trimTreePrefix(line string) (int, string, bool) {
+	depth := 1
+	for {
+		trimmed := false
+		for _, g := range []treeGlyphs{boxGlyphs, asciiGlyphs} {
+			if strings.HasPrefix(line, g.branch) {
+				return depth, line[len(g.branch):], true
+			}
+			if strings.HasPrefix(line, g.last) {
+				return depth, line[len(g.last):], true
+			}
+			if strings.HasPrefix(line, g.pipe) {
+				line, trimmed = line[len(g.pipe):], true
+				break
+			}
+			if strings.HasPrefix(line, g.space) {
+				line, trimmed = line[len(g.space):], true
+				break
+			}
+		}
+		if !trimmed {
+			return 0, line, false
+		}
+		depth++
+	}
+}
+
+func parseTreeLine(line string) (*TreeNode, error) {
+	node := &TreeNode{}
+	if strings.HasPrefix(line, "[") {
+		end := strings.Index(line, "]  ")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed bracket in %q: %w", line, os.ErrInvalid)
+		}
+		for _, field := range strings.Fields(line[1:end]) {
+			if size, err := strconv.ParseInt(field, 10, 64); err == nil {
+				node.Size = size
+				continue
+			}
+			mode, err := parsePerm(field)
+			if err != nil {
+				return nil, err
+			}
+			node.Mode = mode
+		}
+		line = line[end+3:]
+	}
+
+	if name, target, ok := strings.Cut(line, " -> "); ok {
+		node.Name, node.Target = name, target
+	} else if name, ok := strings.CutSuffix(line, "/"); ok {
+		node.Name, node.Dir = name, true
+	} else {
+		node.Name = line
+	}
+	if node.Name == "" {
+		return nil, fmt.Errorf("entry without name: %w", os.ErrInvalid)
+	}
+	return node, nil
+}
+
+// Create creates the children of n inside the dest directory (which is created
+// if it doesn't exist). Files are created with the size of the node (filled with
+// zeros) and symlinks point to their original target. If the node has
+// permissions they're applied once the whole directory has been created, so
+// read-only directories can be populated, otherwise the directories created
+// get 0755 and the files 0644. Existing directories keep their permissions
+// unless the node has some.
+func (n *TreeNode) Create(dest string) error {
+	return wrapErr("create", "", dest, n.create(dest))
+}
+
+func (n *TreeNode) create(dest string) error {
+	exists, err := Exists(dest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	for _, c := range n.Children {
+		p := filepath.Join(dest, c.Name)
+		switch {
+		case c.Dir:
+			if err := c.create(p); err != nil {
+				return err
+			}
+		case c.Target != "":
+			if err := os.Symlink(c.Target, p); err != nil {
+				return err
+			}
+		default:
+			fileMode := c.Mode
+			if fileMode == 0 {
+				fileMode = 0644
+			}
+			f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode)
+			if err != nil {
+				return err
+			}
+			err = f.Truncate(c.Size)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err == nil && c.Mode != 0 {
+				// The umask may have stripped some bits.
+				err = os.Chmod(p, c.Mode)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	switch {
+	case n.Mode != 0:
+		return os.Chmod(dest, n.Mode)
+	case !exists:
+		return os.Chmod(dest, 0755)
+	}
+	return nil
+}
+
+// BuildTree parses text with [ParseTree] and creates it's contents inside dest.
+// It's meant to declare test fixtures in the same format used by golden files.
+// The name of the root line is ignored.
+func BuildTree(text, dest string) error {
+	root, err := ParseTree(strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	return root.Create(dest)
+}
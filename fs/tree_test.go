@@ -0,0 +1,107 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+const testTree = `fixture/
+├── [-rw-r--r--          5]  a.txt
+├── [lrwxrwxrwx          5]  link -> a.txt
+└── [drwxr-x---       4096]  sub/
+    ├── [-rw-------          0]  empty
+    └── [drwxr-xr-x       4096]  nested/
+        └── [-rwxr-xr-x         12]  run.sh
+`
+
+func TestTreeRoundTrip(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "fixture")
+	if err := fs.BuildTree(testTree, root); err != nil {
+		t.Fatal(err)
+	}
+
+	// Directory sizes depend on the file system, so they're left out.
+	opts := fs.TreeOptions{Perms: true, DirsFirst: true}
+	got, err := fs.RenderTree(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[drwxr-xr-x]  fixture/
+├── [drwxr-x---]  sub/
+│   ├── [drwxr-xr-x]  nested/
+│   │   └── [-rwxr-xr-x]  run.sh
+│   └── [-rw-------]  empty
+├── [-rw-r--r--]  a.txt
+└── [lrwxrwxrwx]  link -> a.txt
+`
+	if got != want {
+		t.Errorf("EXPECTED:\n%s\nGOT:\n%s", want, got)
+	}
+
+	opts = fs.TreeOptions{ASCII: true, Sizes: true, Sort: fs.SortBySize, Reverse: true, Ignore: []string{"sub/*", "link"}}
+	got, err = fs.RenderTree(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bigger entries go first, so sub is listed before a.txt.
+	node, err := fs.ParseTree(strings.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Children) != 2 || node.Children[1].Name != "a.txt" || node.Children[1].Size != 5 {
+		t.Errorf("unexpected ASCII tree:\n%s", got)
+	}
+	if sub := node.Children[0]; sub.Name != "sub" || !sub.Dir || len(sub.Children) != 0 {
+		t.Errorf("unexpected ASCII tree:\n%s", got)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestWriteTreeError(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "fixture")
+	if err := fs.BuildTree(testTree, root); err != nil {
+		t.Fatal(err)
+	}
+	errFull := errors.New("disk full")
+	err := fs.WriteTree(failingWriter{errFull}, root, fs.TreeOptions{})
+	if !errors.Is(err, errFull) {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
+
+func TestTreeNodeCreatePermissions(t *testing.T) {
+	dest := t.TempDir()
+	if err := os.Chmod(dest, 0700); err != nil {
+		t.Fatal(err)
+	}
+	node := fs.TreeNode{Dir: true, Children: []*fs.TreeNode{
+		{Name: "shared.txt", Mode: 0666},
+		{Name: "new", Dir: true},
+	}}
+	if err := node.Create(dest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The umask doesn't apply to explicit modes and dest keeps it's own.
+	for name, mode := range map[string]os.FileMode{".": 0700, "shared.txt": 0666, "new": 0755} {
+		info, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("expected %s to have mode %v, got %v", name, mode, info.Mode().Perm())
+		}
+	}
+}
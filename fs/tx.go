@@ -0,0 +1,235 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// ErrTxDone is returned by any [Tx] method called after the transaction has
+// been committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// ChangeOp is the kind of change recorded by a [Tx].
+type ChangeOp int
+
+const (
+	Created ChangeOp = iota
+	Overwritten
+	Deleted
+	Moved
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case Created:
+		return "created"
+	case Overwritten:
+		return "overwritten"
+	case Deleted:
+		return "deleted"
+	case Moved:
+		return "moved"
+	}
+	return "unknown"
+}
+
+// Change is a single change performed inside a [Tx]. From is only set for
+// [Moved] changes.
+type Change struct {
+	Op   ChangeOp
+	Path string
+	From string
+}
+
+// txOp is a change plus what's needed to undo it.
+type txOp struct {
+	Change
+	backup string // Where the previous version of Path was moved, if any
+}
+
+// Tx groups several file system operations so they can be undone together.
+// Every entry a Tx overwrites or deletes is moved to a backup directory instead
+// of being destroyed, so if any step of a multi-step operation (eg: a deployment)
+// fails, [Tx.Rollback] restores the tree to the state it had before the
+// transaction started. Once everything succeeds, [Tx.Commit] discards the backups.
+//
+//	tx, err := fs.NewTx("")
+//	if err != nil {
+//		return err
+//	}
+//	defer tx.Rollback() // No-op if the transaction is committed
+//
+//	if err := tx.CopyDir("build/static", "/srv/www/static"); err != nil {
+//		return err
+//	}
+//	if _, err := tx.CopyFile("build/index.html", "/srv/www/index.html"); err != nil {
+//		return err
+//	}
+//	return tx.Commit()
+//
+// Unlike [CopyFile] and [CopyDir], the methods of Tx replace existing
+// destinations since they can always be restored. A Tx only undoes the changes
+// made through it and it's safe for concurrent use.
+type Tx struct {
+	mu        sync.Mutex
+	backupDir string
+	ops       []txOp
+	done      bool
+}
+
+// NewTx starts a transaction keeping the backups in a new directory inside
+// backupDir (or inside [os.TempDir] if it's empty). Backups are moved instead
+// of copied, so a backupDir in the same file system as the changed entries is
+// faster.
+func NewTx(backupDir string) (*Tx, error) {
+	dir, err := os.MkdirTemp(backupDir, "yagul-tx-*")
+	if err != nil {
+		return nil, &Error{Op: "mkdir", Dest: backupDir, Err: err}
+	}
+	return &Tx{backupDir: dir}, nil
+}
+
+// Changes returns the changes recorded so far in the order they happened.
+func (tx *Tx) Changes() []Change {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	changes := make([]Change, len(tx.ops))
+	for i, op := range tx.ops {
+		changes[i] = op.Change
+	}
+	return changes
+}
+
+// record moves the current version of path (if any) to the backup directory
+// and records the change. It's called before performing the change, so a change
+// that fails halfway is undone too.
+func (tx *Tx) record(op ChangeOp, path, from string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+
+	exists, err := Lexists(path)
+	if err != nil {
+		return err
+	}
+	backup := ""
+	if exists {
+		backup = filepath.Join(tx.backupDir, strconv.Itoa(len(tx.ops)))
+		if err := move(path, backup); err != nil {
+			return err
+		}
+		if op == Created {
+			op = Overwritten
+		}
+	}
+	tx.ops = append(tx.ops, txOp{Change{op, path, from}, backup})
+	return nil
+}
+
+// CopyFile copies source to dest like [CopyFile], replacing dest if it exists.
+func (tx *Tx) CopyFile(source, dest string) (int64, error) {
+	if err := tx.record(Created, dest, ""); err != nil {
+		return 0, wrapErr("backup", source, dest, err)
+	}
+	return CopyFile(source, dest)
+}
+
+// CopyDir copies source to dest like [CopyDir]. If dest exists it's replaced as
+// a whole, it's contents aren't merged.
+func (tx *Tx) CopyDir(source, dest string) error {
+	if err := tx.record(Created, dest, ""); err != nil {
+		return wrapErr("backup", source, dest, err)
+	}
+	return CopyDir(source, dest)
+}
+
+// WriteFile writes data to name like [WriteFileAtomic], replacing name if it
+// exists.
+func (tx *Tx) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := tx.record(Created, name, ""); err != nil {
+		return wrapErr("backup", "", name, err)
+	}
+	return WriteFileAtomic(name, data, perm)
+}
+
+// Move moves source to dest like [Move], replacing dest if it exists.
+func (tx *Tx) Move(source, dest string) error {
+	if _, err := os.Lstat(source); err != nil {
+		return &Error{Op: "stat", Source: source, Dest: dest, Err: err}
+	}
+	if err := tx.record(Moved, dest, source); err != nil {
+		return wrapErr("backup", source, dest, err)
+	}
+	return move(source, dest)
+}
+
+// Remove removes path and all it's contents like [os.RemoveAll]. It's moved to
+// the backup directory, so it's only gone for good once the transaction is
+// committed.
+func (tx *Tx) Remove(path string) error {
+	exists, err := Lexists(path)
+	if err != nil || !exists {
+		return err
+	}
+	return wrapErr("remove", path, "", tx.record(Deleted, path, ""))
+}
+
+// Commit ends the transaction and removes the backups.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return &Error{Op: "commit", Source: tx.backupDir, Err: ErrTxDone}
+	}
+	tx.done = true
+	return wrapErr("commit", tx.backupDir, "", os.RemoveAll(tx.backupDir))
+}
+
+// Rollback ends the transaction undoing it's changes from the last to the
+// first one. It tries to undo every change even if some of them fail, in that
+// case the errors are joined and the backup directory is kept so nothing is
+// lost. Calling Rollback after Commit is a no-op that returns [ErrTxDone], so
+// it can be safely deferred.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return &Error{Op: "rollback", Source: tx.backupDir, Err: ErrTxDone}
+	}
+	tx.done = true
+
+	var errs []error
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		op := tx.ops[i]
+		if op.Op == Moved {
+			// If the move failed, the entry is still in it's original place.
+			moved, err := Lexists(op.Path)
+			if err == nil && moved {
+				err = move(op.Path, op.From)
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		} else if err := os.RemoveAll(op.Path); err != nil {
+			errs = append(errs, &Error{Op: "remove", Source: op.Path, Err: err})
+			continue
+		}
+		if op.backup != "" {
+			if err := move(op.backup, op.Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		err := fmt.Errorf("rollback incomplete, backups kept in %s: %w", tx.backupDir, errors.Join(errs...))
+		return &Error{Op: "rollback", Source: tx.backupDir, Err: err}
+	}
+	return wrapErr("rollback", tx.backupDir, "", os.RemoveAll(tx.backupDir))
+}
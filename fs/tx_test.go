@@ -0,0 +1,128 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestTxRollback(t *testing.T) {
+	root := t.TempDir()
+	original := fs.Fixture{
+		"release/index.html": fs.FileEntry("v1"),
+		"release/old.css":    fs.FileEntry("body {}"),
+		"config.json":        fs.FileEntry("{}"),
+		"incoming/app.js":    fs.FileEntry("console.log(2)"),
+		"build/index.html":   fs.FileEntry("v2"),
+	}
+	if err := original.Create(root); err != nil {
+		t.Fatal(err)
+	}
+	p := func(rel string) string { return filepath.Join(root, rel) }
+
+	tx, err := fs.NewTx(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CopyDir(p("build"), p("release")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.WriteFile(p("config.json"), []byte(`{"version": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Move(p("incoming/app.js"), p("release/app.js")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Remove(p("build")); err != nil {
+		t.Fatal(err)
+	}
+	// The last step fails, leaving the tree half deployed.
+	if _, err := tx.CopyFile(p("missing.txt"), p("release/missing.txt")); err == nil {
+		t.Fatal("expected copying a missing file to fail")
+	}
+
+	fs.AssertTree(t, root, fs.Fixture{
+		"release/index.html": fs.FileEntry("v2"),
+		"release/app.js":     fs.FileEntry("console.log(2)"),
+		"config.json":        fs.FileEntry(`{"version": 2}`),
+		"incoming/":          fs.DirEntry(),
+	})
+
+	want := []fs.Change{
+		{Op: fs.Overwritten, Path: p("release")},
+		{Op: fs.Overwritten, Path: p("config.json")},
+		{Op: fs.Moved, Path: p("release/app.js"), From: p("incoming/app.js")},
+		{Op: fs.Deleted, Path: p("build")},
+		{Op: fs.Created, Path: p("release/missing.txt")},
+	}
+	changes := tx.Changes()
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, original)
+
+	if err := tx.Commit(); !errors.Is(err, fs.ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	root := t.TempDir()
+	backups := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := fs.NewTx(backups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.WriteFile(filepath.Join(root, "a"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{"a": fs.FileEntry("new")})
+	if empty, _ := fs.IsEmptyDir(backups); !empty {
+		t.Error("backups were not removed on commit")
+	}
+	if err := tx.Rollback(); !errors.Is(err, fs.ErrTxDone) {
+		t.Errorf("expected ErrTxDone, got %v", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/a":    fs.FileEntry("a"),
+		"src/link": fs.SymlinkEntry("a"),
+		"taken":    fs.FileEntry(""),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Move(filepath.Join(root, "src"), filepath.Join(root, "taken")); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected os.ErrExist, got %v", err)
+	}
+	if err := fs.Move(filepath.Join(root, "src"), filepath.Join(root, "dst")); err != nil {
+		t.Fatal(err)
+	}
+	fs.AssertTree(t, root, fs.Fixture{
+		"dst/a":    fs.FileEntry("a"),
+		"dst/link": fs.SymlinkEntry("a"),
+		"taken":    fs.FileEntry(""),
+	})
+}
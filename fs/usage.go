@@ -0,0 +1,277 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// UsageOptions tunes how [UsageContext] walks the tree. The zero value is
+// ready to use.
+type UsageOptions struct {
+	// Depth limits the per directory summary like the -d flag of du: 0 only
+	// summarizes root, 1 also summarizes it's direct subdirectories and so on.
+	// A negative value summarizes every directory.
+	Depth int
+	// Top is the number of largest files kept in [DiskUsage.Largest]. If it's
+	// 0 (or less), the 10 largest files are kept.
+	Top int
+	// Workers is the maximum number of directories read at the same time. If
+	// it's 0, runtime.NumCPU() is used.
+	Workers int
+	// Ignore holds patterns of entries left out of the report, with the same
+	// syntax as [TreeOptions.Ignore].
+	Ignore []string
+}
+
+// FileUsage is a file path and it's apparent size.
+type FileUsage struct {
+	Path string
+	Size int64
+}
+
+// DirUsage is the summary of a directory and everything below it.
+type DirUsage struct {
+	Path      string
+	Depth     int
+	Size      int64
+	Allocated int64
+}
+
+// ExtUsage holds how many files share an extension and their total size.
+type ExtUsage struct {
+	Files int
+	Size  int64
+}
+
+// DiskUsage is the report returned by [Usage] and [UsageContext].
+type DiskUsage struct {
+	// Size is the sum of the apparent size of files and symlinks.
+	Size int64
+	// Allocated is the space reserved on disk by every entry, directories
+	// included.
+	Allocated int64
+	Files     int
+	Dirs      int
+	Symlinks  int
+	// Largest holds the biggest files sorted from largest to smallest.
+	Largest []FileUsage
+	// Extensions groups files by their lowercased extension (with the
+	// leading dot). Files without extension are grouped under "".
+	Extensions map[string]ExtUsage
+	// Summary holds a [DirUsage] per directory up to [UsageOptions.Depth],
+	// sorted by path.
+	Summary []DirUsage
+}
+
+// Usage walks root and reports it's disk usage like the du command does. Hard
+// links are counted once and symlinks are not followed. It's the same as calling
+// [UsageContext] with a background context and the default options.
+func Usage(root string) (*DiskUsage, error) {
+	return UsageContext(context.Background(), root, UsageOptions{})
+}
+
+// UsageContext does the same as [Usage] but reads several directories
+// concurrently and stops as soon as ctx is cancelled, returning ctx's error. If
+// any entry can't be read, the walk is aborted and that error is returned (entries
+// removed while walking are skipped).
+func UsageContext(ctx context.Context, root string, opts UsageOptions) (*DiskUsage, error) {
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, &Error{Op: "lstat", Source: root, Err: err}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	w := usageWalker{
+		root:   root,
+		ctx:    ctx,
+		cancel: cancel,
+		opts:   opts,
+		sem:    make(chan struct{}, opts.Workers),
+		seen:   map[fileKey]bool{},
+		report: DiskUsage{Extensions: map[string]ExtUsage{}},
+	}
+	top := w.newNode(root, 0, nil)
+	w.entry(root, info, 0, top)
+	w.wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, wrapErr("usage", root, "", err)
+	}
+
+	for _, n := range w.nodes {
+		w.report.Summary = append(w.report.Summary, DirUsage{
+			Path:      n.path,
+			Depth:     n.depth,
+			Size:      n.size.Load(),
+			Allocated: n.allocated.Load(),
+		})
+	}
+	sort.Slice(w.report.Summary, func(i, j int) bool {
+		return w.report.Summary[i].Path < w.report.Summary[j].Path
+	})
+	return &w.report, nil
+}
+
+// usageNode accumulates the sizes of a summarized directory. Every entry adds
+// it's size to the node of it's directory and all of it's ancestors.
+type usageNode struct {
+	path      string
+	depth     int
+	parent    *usageNode
+	size      atomic.Int64
+	allocated atomic.Int64
+}
+
+func (n *usageNode) add(size, allocated int64) {
+	for ; n != nil; n = n.parent {
+		n.size.Add(size)
+		n.allocated.Add(allocated)
+	}
+}
+
+type usageWalker struct {
+	root   string
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	opts   UsageOptions
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	seen   map[fileKey]bool
+	nodes  []*usageNode
+	report DiskUsage
+}
+
+func (w *usageWalker) newNode(path string, depth int, parent *usageNode) *usageNode {
+	n := &usageNode{path: path, depth: depth, parent: parent}
+	w.mu.Lock()
+	w.nodes = append(w.nodes, n)
+	w.mu.Unlock()
+	return n
+}
+
+// entry accounts a single entry and, if it's a directory, schedules it's
+// contents to be read.
+func (w *usageWalker) entry(path string, info os.FileInfo, depth int, node *usageNode) {
+	allocated := allocatedSize(info)
+
+	switch {
+	case info.IsDir():
+		w.mu.Lock()
+		w.report.Dirs++
+		w.report.Allocated += allocated
+		w.mu.Unlock()
+		node.add(0, allocated)
+
+		w.wg.Add(1)
+		select {
+		case w.sem <- struct{}{}:
+			go func() {
+				defer func() { <-w.sem }()
+				w.dir(path, depth, node)
+			}()
+		default:
+			// All workers are busy, read it in this goroutine instead of
+			// waiting for one to be released.
+			w.dir(path, depth, node)
+		}
+		return
+
+	case info.Mode()&os.ModeSymlink != 0:
+		w.mu.Lock()
+		w.report.Symlinks++
+		w.report.Size += info.Size()
+		w.report.Allocated += allocated
+		w.mu.Unlock()
+		node.add(info.Size(), allocated)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if key, links, ok := inodeOf(info); ok && links > 1 {
+		if w.seen[key] {
+			return
+		}
+		w.seen[key] = true
+	}
+	w.report.Files++
+	w.report.Size += info.Size()
+	w.report.Allocated += allocated
+	ext := strings.ToLower(filepath.Ext(info.Name()))
+	e := w.report.Extensions[ext]
+	e.Files++
+	e.Size += info.Size()
+	w.report.Extensions[ext] = e
+	w.addLargest(FileUsage{path, info.Size()})
+	node.add(info.Size(), allocated)
+}
+
+func (w *usageWalker) dir(path string, depth int, node *usageNode) {
+	defer w.wg.Done()
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		w.cancel(&Error{Op: "readdir", Source: path, Err: err})
+		return
+	}
+
+	for _, e := range entries {
+		if w.ctx.Err() != nil {
+			return
+		}
+		entryPath := filepath.Join(path, e.Name())
+		if rel, _ := filepath.Rel(w.root, entryPath); ignored(w.opts.Ignore, rel) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			w.cancel(&Error{Op: "lstat", Source: entryPath, Err: err})
+			return
+		}
+
+		child := node
+		if e.IsDir() && (w.opts.Depth < 0 || depth+1 <= w.opts.Depth) {
+			child = w.newNode(entryPath, depth+1, node)
+		}
+		w.entry(entryPath, info, depth+1, child)
+	}
+}
+
+// addLargest keeps report.Largest sorted and no longer than opts.Top. It must
+// be called with w.mu held.
+func (w *usageWalker) addLargest(f FileUsage) {
+	largest := w.report.Largest
+	if len(largest) == w.opts.Top && f.Size <= largest[len(largest)-1].Size {
+		return
+	}
+	i := sort.Search(len(largest), func(i int) bool { return largest[i].Size < f.Size })
+	if len(largest) < w.opts.Top {
+		largest = append(largest, FileUsage{})
+	}
+	copy(largest[i+1:], largest[i:])
+	largest[i] = f
+	w.report.Largest = largest
+}
@@ -0,0 +1,110 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestUsage(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt":       "hello",
+		"b.TXT":       "hi",
+		"sub/c.go":    "package c",
+		"sub/deep/d":  "1234567890",
+		"other/e.bin": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(root, "a.txt"), filepath.Join(root, "a-link.txt")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := fs.UsageContext(context.Background(), root, fs.UsageOptions{Depth: 1, Top: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Files != 5 {
+		t.Errorf("expected 5 files (hard link counted once), got %d", u.Files)
+	}
+	if u.Dirs != 4 || u.Symlinks != 1 {
+		t.Errorf("expected 4 dirs and 1 symlink, got %d and %d", u.Dirs, u.Symlinks)
+	}
+	if want := int64(5+2+9+10) + int64(len("a.txt")); u.Size != want {
+		t.Errorf("expected size %d, got %d", want, u.Size)
+	}
+	if len(u.Largest) != 2 || u.Largest[0].Size != 10 || u.Largest[1].Size != 9 {
+		t.Errorf("unexpected largest files: %v", u.Largest)
+	}
+	if e := u.Extensions[".txt"]; e.Files != 2 || e.Size != 7 {
+		t.Errorf("unexpected .txt usage: %+v", e)
+	}
+	if len(u.Summary) != 3 {
+		t.Fatalf("expected root, other and sub in the summary, got %v", u.Summary)
+	}
+	if sub := u.Summary[2]; sub.Path != filepath.Join(root, "sub") || sub.Size != 19 {
+		t.Errorf("unexpected summary of sub: %+v", sub)
+	}
+}
+
+func TestUsageCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := fs.UsageContext(ctx, t.TempDir(), fs.UsageOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUsageNegativeTop(t *testing.T) {
+	root := t.TempDir()
+	for i := range 12 {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%02d.txt", i)), make([]byte, i), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := fs.UsageContext(context.Background(), root, fs.UsageOptions{Top: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.Largest) != 10 || u.Largest[0].Size != 11 {
+		t.Errorf("expected the 10 largest files by default, got %v", u.Largest)
+	}
+}
+
+func TestUsageIgnore(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"a.txt":             fs.FileEntry("aaaa"),
+		"node_modules/x.js": fs.FileEntry("xxxxxxxx"),
+		"src/b.txt":         fs.FileEntry("bb"),
+		"src/generated.txt": fs.FileEntry("gggggg"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := fs.UsageContext(context.Background(), root, fs.UsageOptions{Ignore: []string{"node_modules", "src/generated.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Files != 2 || u.Size != 6 || u.Dirs != 2 {
+		t.Errorf("expected 2 files of 6 bytes in 2 dirs, got %d files of %d bytes in %d dirs", u.Files, u.Size, u.Dirs)
+	}
+}
@@ -0,0 +1,72 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// copiedXattrs are the prefixes of the extended attributes copied with
+// [CopyOptions.Xattrs]: user attributes, security labels (eg: SELinux labels
+// and file capabilities) and POSIX ACLs.
+var copiedXattrs = []string{"user.", "security.", "system.posix_acl_access", "system.posix_acl_default"}
+
+// XattrFailure is an extended attribute that couldn't be copied.
+type XattrFailure struct {
+	Path string // Destination path
+	Name string
+	Err  error
+}
+
+// XattrError is returned (wrapped in an [*Error]) by copies with
+// [CopyOptions.Xattrs] when some extended attributes couldn't be copied (eg:
+// security.* ones without the needed privileges or to a file system without
+// support for them). Everything else was copied, so it can be ignored if the
+// attributes aren't essential.
+type XattrError struct {
+	Failures []XattrFailure
+}
+
+func (e *XattrError) Error() string {
+	names := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		names[i] = fmt.Sprintf("%s of %s (%v)", f.Name, f.Path, f.Err)
+	}
+	return fmt.Sprintf("%d extended attributes could not be copied: %s", len(e.Failures), strings.Join(names, ", "))
+}
+
+// copyXattrs copies the extended attributes of source to dest and returns the
+// ones that failed. If source doesn't support extended attributes, there's
+// nothing to copy.
+func copyXattrs(source, dest string) []XattrFailure {
+	names, err := ListXattr(source)
+	if err != nil {
+		if xattrUnsupported(err) {
+			return nil
+		}
+		return []XattrFailure{{Path: dest, Err: err}}
+	}
+
+	var failures []XattrFailure
+	for _, name := range names {
+		if !copiedXattr(name) {
+			continue
+		}
+		value, err := GetXattr(source, name)
+		if err == nil {
+			err = SetXattr(dest, name, value)
+		}
+		if err != nil {
+			failures = append(failures, XattrFailure{dest, name, err})
+		}
+	}
+	return failures
+}
+
+func copiedXattr(name string) bool {
+	for _, prefix := range copiedXattrs {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// GetXattr returns the value of the extended attribute name of the file at
+// path, following symlinks.
+func GetXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			// The value grew since it's size was checked.
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
+
+// SetXattr sets the extended attribute name of the file at path to value,
+// following symlinks.
+func SetXattr(path, name string, value []byte) error {
+	if err := syscall.Setxattr(path, name, value, 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// ListXattr returns the names of the extended attributes of the file at path,
+// following symlinks. Only the attributes the caller is allowed to see are
+// listed (eg: trusted.* ones are only listed for root).
+func ListXattr(path string) ([]string, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+		}
+		var names []string
+		for _, name := range strings.Split(string(buf[:n]), "\x00") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// RemoveXattr removes the extended attribute name of the file at path,
+// following symlinks.
+func RemoveXattr(path, name string) error {
+	if err := syscall.Removexattr(path, name); err != nil {
+		return &os.PathError{Op: "removexattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// xattrUnsupported reports wether err was returned because the file system
+// doesn't support extended attributes.
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, errors.ErrUnsupported)
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+)
+
+// GetXattr is only supported on Linux, elsewhere it returns an
+// [errors.ErrUnsupported] error.
+func GetXattr(path, name string) ([]byte, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: errors.ErrUnsupported}
+}
+
+// SetXattr is only supported on Linux, elsewhere it returns an
+// [errors.ErrUnsupported] error.
+func SetXattr(path, name string, value []byte) error {
+	return &os.PathError{Op: "setxattr", Path: path, Err: errors.ErrUnsupported}
+}
+
+// ListXattr is only supported on Linux, elsewhere it returns an
+// [errors.ErrUnsupported] error.
+func ListXattr(path string) ([]string, error) {
+	return nil, &os.PathError{Op: "listxattr", Path: path, Err: errors.ErrUnsupported}
+}
+
+// RemoveXattr is only supported on Linux, elsewhere it returns an
+// [errors.ErrUnsupported] error.
+func RemoveXattr(path, name string) error {
+	return &os.PathError{Op: "removexattr", Path: path, Err: errors.ErrUnsupported}
+}
+
+func xattrUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported)
+}
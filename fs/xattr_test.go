@@ -0,0 +1,64 @@
+package fs_test
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestXattrs(t *testing.T) {
+	root := t.TempDir()
+	err := fs.Fixture{
+		"src/file":     fs.FileEntry("content"),
+		"src/sub/file": fs.FileEntry("nested"),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "src/file")
+	if err := fs.SetXattr(file, "user.origin", []byte("tests")); err != nil {
+		t.Skipf("user extended attributes are not supported: %v", err)
+	}
+	if err := fs.SetXattr(filepath.Join(root, "src/sub"), "user.kind", []byte("dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fs.ListXattr(file)
+	if err != nil || !slices.Contains(names, "user.origin") {
+		t.Errorf("expected user.origin in %v (%v)", names, err)
+	}
+	value, err := fs.GetXattr(file, "user.origin")
+	if err != nil || string(value) != "tests" {
+		t.Errorf("expected tests, got %q (%v)", value, err)
+	}
+
+	// Without the option, attributes aren't copied.
+	if _, err := fs.CopyFile(file, filepath.Join(root, "plain")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.GetXattr(filepath.Join(root, "plain"), "user.origin"); err == nil {
+		t.Error("the attribute was copied without the Xattrs option")
+	}
+
+	err = fs.CopyDirWithOptions(filepath.Join(root, "src"), filepath.Join(root, "dest"), fs.CopyOptions{Xattrs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"dest/file:user.origin": "tests", "dest/sub:user.kind": "dir"} {
+		p, name, _ := strings.Cut(path, ":")
+		value, err := fs.GetXattr(filepath.Join(root, p), name)
+		if err != nil || string(value) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", path, want, value, err)
+		}
+	}
+
+	if err := fs.RemoveXattr(file, "user.origin"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := fs.ListXattr(file); slices.Contains(names, "user.origin") {
+		t.Error("the attribute was not removed")
+	}
+}
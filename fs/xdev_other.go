@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package fs
+
+// isCrossDevice always returns false in platforms where it can't be detected,
+// so moves between file systems fail instead of falling back to a copy.
+func isCrossDevice(err error) bool {
+	return false
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package fs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice reports wether err was returned because a rename tried to move
+// an entry to another file system.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
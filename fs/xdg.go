@@ -0,0 +1,170 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The functions below return the base directories of the XDG Base Directory
+// spec (https://specifications.freedesktop.org/basedir-spec/latest/): the
+// value of the environment variable if it's set to an absolute path (relative
+// ones must be ignored according to the spec) or the default location inside
+// the home directory otherwise. They don't create the directories, use
+// [AppDirs] for that.
+
+// ConfigHome returns $XDG_CONFIG_HOME, ~/.config by default.
+func ConfigHome() (string, error) {
+	return xdgHome("XDG_CONFIG_HOME", ".config")
+}
+
+// CacheHome returns $XDG_CACHE_HOME, ~/.cache by default.
+func CacheHome() (string, error) {
+	return xdgHome("XDG_CACHE_HOME", ".cache")
+}
+
+// DataHome returns $XDG_DATA_HOME, ~/.local/share by default.
+func DataHome() (string, error) {
+	return xdgHome("XDG_DATA_HOME", ".local", "share")
+}
+
+// StateHome returns $XDG_STATE_HOME, ~/.local/state by default.
+func StateHome() (string, error) {
+	return xdgHome("XDG_STATE_HOME", ".local", "state")
+}
+
+// RuntimeDir returns $XDG_RUNTIME_DIR. The spec doesn't have a default for it,
+// so it returns an [os.ErrNotExist] error if it's not set.
+func RuntimeDir() (string, error) {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	return "", fmt.Errorf("XDG_RUNTIME_DIR is not set: %w", os.ErrNotExist)
+}
+
+// ConfigDirs returns the directories of $XDG_CONFIG_DIRS in order of
+// preference, /etc/xdg by default. It doesn't include [ConfigHome].
+func ConfigDirs() []string {
+	return xdgDirs("XDG_CONFIG_DIRS", "/etc/xdg")
+}
+
+// DataDirs returns the directories of $XDG_DATA_DIRS in order of preference,
+// /usr/local/share and /usr/share by default. It doesn't include [DataHome].
+func DataDirs() []string {
+	return xdgDirs("XDG_DATA_DIRS", "/usr/local/share", "/usr/share")
+}
+
+func xdgHome(env string, def ...string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{home}, def...)...), nil
+}
+
+func xdgDirs(env string, def ...string) []string {
+	var dirs []string
+	for _, dir := range strings.Split(os.Getenv(env), string(os.PathListSeparator)) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return def
+	}
+	return dirs
+}
+
+// AppDirs has the XDG directories of an application, which are the base
+// directories with Name appended (eg: ~/.config/myapp). Directories are
+// created on first use with permissions 0700, as the spec requires, but
+// existing ones are left untouched.
+type AppDirs struct {
+	Name string
+}
+
+// ConfigDir returns the config directory of the application, creating it if
+// it doesn't exist.
+func (a AppDirs) ConfigDir() (string, error) {
+	return a.create(ConfigHome())
+}
+
+// CacheDir returns the cache directory of the application, creating it if it
+// doesn't exist.
+func (a AppDirs) CacheDir() (string, error) {
+	return a.create(CacheHome())
+}
+
+// DataDir returns the data directory of the application, creating it if it
+// doesn't exist.
+func (a AppDirs) DataDir() (string, error) {
+	return a.create(DataHome())
+}
+
+// StateDir returns the state directory of the application, creating it if it
+// doesn't exist.
+func (a AppDirs) StateDir() (string, error) {
+	return a.create(StateHome())
+}
+
+// RuntimeDir returns the runtime directory of the application, creating it
+// if it doesn't exist.
+func (a AppDirs) RuntimeDir() (string, error) {
+	return a.create(RuntimeDir())
+}
+
+func (a AppDirs) create(base string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if a.Name == "" {
+		return "", fmt.Errorf("empty application name: %w", os.ErrInvalid)
+	}
+	dir := filepath.Join(base, a.Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// FindConfig returns the path of the first existing config file called name,
+// looking in the config directory of the application and then in the ones in
+// [ConfigDirs], in order of preference. It returns an [os.ErrNotExist] error if
+// there's none. Unlike ConfigDir, it doesn't create any directory.
+func (a AppDirs) FindConfig(name string) (string, error) {
+	files, err := a.ConfigFiles(name)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("config file %s of %s not found: %w", name, a.Name, os.ErrNotExist)
+	}
+	return files[0], nil
+}
+
+// ConfigFiles returns the paths of every existing config file called name in
+// the same order as [AppDirs.FindConfig] looks for them, so the values of the
+// first ones should take precedence when they're merged.
+func (a AppDirs) ConfigFiles(name string) ([]string, error) {
+	dirs := ConfigDirs()
+	if home, err := ConfigHome(); err == nil {
+		dirs = append([]string{home}, dirs...)
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		path := filepath.Join(dir, a.Name, name)
+		exists, err := Exists(path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,65 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/n-mou/yagul/fs"
+)
+
+func TestXDG(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Setenv("USERPROFILE", root)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(root, "cache"))
+	t.Setenv("XDG_DATA_HOME", "relative/paths/are/ignored")
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(root, "etc1")+string(os.PathListSeparator)+filepath.Join(root, "etc2"))
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	for name, f := range map[string]func() (string, error){
+		".config":      fs.ConfigHome,
+		"cache":        fs.CacheHome,
+		".local/share": fs.DataHome,
+		".local/state": fs.StateHome,
+	} {
+		dir, err := f()
+		if err != nil || dir != filepath.Join(root, name) {
+			t.Errorf("expected %s, got %s (%v)", filepath.Join(root, name), dir, err)
+		}
+	}
+	if _, err := fs.RuntimeDir(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error without XDG_RUNTIME_DIR, got %v", err)
+	}
+
+	app := fs.AppDirs{Name: "myapp"}
+	dir, err := app.CacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("the cache dir was not created: %v", err)
+	} else if info.Mode().Perm() != 0700 && os.PathSeparator == '/' {
+		t.Errorf("expected permissions 0700, got %v", info.Mode().Perm())
+	}
+
+	if _, err := app.FindConfig("config.toml"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	err = fs.Fixture{
+		".config/myapp/config.toml": fs.FileEntry(""),
+		"etc2/myapp/config.toml":    fs.FileEntry(""),
+		"etc1/other/config.toml":    fs.FileEntry(""),
+	}.Create(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := app.ConfigFiles("config.toml")
+	want := []string{filepath.Join(root, ".config/myapp/config.toml"), filepath.Join(root, "etc2/myapp/config.toml")}
+	if err != nil || !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v (%v)", want, files, err)
+	}
+}